@@ -0,0 +1,187 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Converter converts html into md with a fixed set of options. It holds no
+// state between calls and is safe for concurrent use by multiple goroutines.
+type Converter struct {
+	opts *options
+}
+
+// NewConverter returns a Converter configured by opts.
+func NewConverter(opts ...Option) *Converter {
+	return &Converter{opts: newOptions(opts)}
+}
+
+// Convert converts html into md. Problems found in the html, such as stray
+// end tags, are returned as an *Error along with the md, which is converted
+// from the tree a browser would build. A panic during the conversion is
+// returned as an *Error too, instead of reaching the caller, with empty md.
+func (c *Converter) Convert(html string) (md string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			md, err = "", &Error{
+				Problems: []Problem{{Msg: fmt.Sprint(v)}},
+				panic:    v,
+			}
+		}
+	}()
+
+	root, problems := parseProblems(html)
+	md = newRenderer(c.opts, root).document()
+	if len(problems) > 0 {
+		return md, &Error{Problems: problems}
+	}
+	return md, nil
+}
+
+// ConvertReader converts the html read from r into md like Convert. The
+// html is tokenized as it is read, in chunks, rather than read whole first.
+// An error reading r is returned as it is.
+func (c *Converter) ConvertReader(r io.Reader) (md string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			md, err = "", &Error{Problems: []Problem{{Msg: fmt.Sprint(v)}}, panic: v}
+		}
+	}()
+
+	z := newReaderTokenizer(r)
+	root, problems := parseTokens(z)
+	if z.err != nil {
+		return "", z.err
+	}
+
+	md = newRenderer(c.opts, root).document()
+	if len(problems) > 0 {
+		return md, &Error{Problems: problems}
+	}
+	return md, nil
+}
+
+// ConvertTo converts html into md like Convert, writing the md to w block by
+// block as it is converted rather than building it in memory first. It
+// stops at the first error from w and returns it.
+func (c *Converter) ConvertTo(w io.Writer, html string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &Error{Problems: []Problem{{Msg: fmt.Sprint(v)}}, panic: v}
+		}
+	}()
+
+	root, problems := parseProblems(html)
+	if err := newRenderer(c.opts, root).write(w); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// blockWriter writes md blocks separated by blank lines and keeps the first
+// error.
+type blockWriter struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+func (bw *blockWriter) write(blocks ...string) {
+	for _, block := range blocks {
+		if bw.n > 0 {
+			bw.writeString("\n\n")
+		}
+		bw.writeString(block)
+		bw.n++
+	}
+}
+
+func (bw *blockWriter) writeString(s string) {
+	if bw.err != nil {
+		return
+	}
+	n, err := io.WriteString(bw.w, s)
+	if err == nil && n < len(s) {
+		err = io.ErrShortWrite
+	}
+	bw.err = err
+}
+
+// Convert converts html into md with the given options, as
+// NewConverter(opts...).Convert(html) does.
+func Convert(html string, opts ...Option) (string, error) {
+	return NewConverter(opts...).Convert(html)
+}
+
+// ConvertReader converts the html read from r into md with the given
+// options, as NewConverter(opts...).ConvertReader(r) does.
+func ConvertReader(r io.Reader, opts ...Option) (string, error) {
+	return NewConverter(opts...).ConvertReader(r)
+}
+
+// Problem is a problem found while converting html.
+type Problem struct {
+	// Tag is the name of the element involved, if known.
+	Tag string
+	// Line is the line of the html, counting from 1, or 0 if unknown.
+	Line int
+	Msg  string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return "line " + strconv.Itoa(p.Line) + ": " + p.Msg
+	}
+	return p.Msg
+}
+
+// Error is returned by Convert and holds every problem found.
+type Error struct {
+	Problems []Problem
+	panic    interface{}
+}
+
+func (e *Error) Error() string {
+	if len(e.Problems) == 0 {
+		return "html2md: conversion failed"
+	}
+
+	msg := "html2md: " + e.Problems[0].String()
+	if n := len(e.Problems) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
@@ -0,0 +1,143 @@
+package html2md
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+func TestConverter(t *testing.T) {
+	var (
+		c    = NewConverter(WithOrderedListDelimiter(')'), WithHeadingSlugs(true))
+		s    = `<h2 id="p">Pools</h2><ol><li>Get</li><li>Put</li></ol><p><a href="#p">back</a></p>`
+		want = "## Pools\n\n1) Get\n2) Put\n\n[back](#pools)\n"
+		wg   sync.WaitGroup
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if md, err := c.Convert(s); md != want || err != nil {
+					t.Errorf("got %q, %v, want %q", md, err, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConvertError(t *testing.T) {
+	const s = "<div>\n<p>Pools</p></b>\n</div></div>"
+
+	md, err := Convert(s)
+	if md != "Pools\n" {
+		t.Errorf("got %q, want %q", md, "Pools\n")
+	}
+
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	want := []Problem{
+		{Tag: "b", Line: 2, Msg: "unexpected end tag </b>"},
+		{Tag: "div", Line: 3, Msg: "unexpected end tag </div>"},
+	}
+	if !reflect.DeepEqual(e.Problems, want) {
+		t.Errorf("got problems %v, want %v", e.Problems, want)
+	}
+	if msg := "html2md: line 2: unexpected end tag </b> (and 1 more)"; e.Error() != msg {
+		t.Errorf("got message %q, want %q", e.Error(), msg)
+	}
+
+	if _, err := Convert("<p>Pools</p>"); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+// chunkWriter records every write and fails once limit bytes are written.
+type chunkWriter struct {
+	writes []string
+	limit  int
+	size   int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.size+len(p) > w.limit {
+		return 0, errors.New("disk full")
+	}
+	w.writes = append(w.writes, string(p))
+	w.size += len(p)
+	return len(p), nil
+}
+
+func TestConvertTo(t *testing.T) {
+	s := "<html><body><h1>Pools</h1><div><p>Get</p><ul><li>Put</li></ul></div>" +
+		`<p>See<sup id="r1"><a href="#n1">1</a></sup>.</p><ol><li id="n1">docs</li></ol></body></html>`
+
+	// A streamed container that writes out all of its blocks must not leave
+	// later blocks merging into, or numbering after, blocks already written.
+	const written = "<div><p>x</p><div></div></div>"
+
+	tests := []struct {
+		html string
+		opts []Option
+	}{
+		{s, nil},
+		{s, []Option{WithBlankLineAfterHeadings(false)}},
+		{s, []Option{WithWrapInCodeFence("md")}},
+		{"<blockquote>a</blockquote>" + written + "<blockquote>b</blockquote>", []Option{WithMergeBlockquotes(true)}},
+		{"<section><p>a</p>" + written + "</section><section><p>b</p></section>", []Option{WithSectionBreaks(true)}},
+		{"<section><p>a</p></section>" + written + "<section><p>b</p></section>", []Option{WithSectionBreaks(true)}},
+		{"<h1>A</h1>" + written + "<h1>B</h1>", []Option{WithDemoteConsecutiveHeadings(true)}},
+	}
+
+	for _, test := range tests {
+		c := NewConverter(test.opts...)
+		want, _ := c.Convert(test.html)
+
+		w := &chunkWriter{limit: 1 << 20}
+		if err := c.ConvertTo(w, test.html); err != nil || strings.Join(w.writes, "") != want {
+			t.Errorf("%q: got %q, %v, want %q", test.html, strings.Join(w.writes, ""), err, want)
+		}
+	}
+
+	w := &chunkWriter{limit: 1 << 20}
+	NewConverter().ConvertTo(w, s)
+	if len(w.writes) < 4 {
+		t.Errorf("got %d writes, want the md written block by block", len(w.writes))
+	}
+
+	w = &chunkWriter{limit: 10}
+	if err := NewConverter().ConvertTo(w, s); err == nil || err.Error() != "disk full" {
+		t.Errorf("got error %v, want disk full", err)
+	}
+}
+
+func TestConvertReader(t *testing.T) {
+	s := "<h1>Pools &amp; arenas</h1>\n<p>Use <code>sync.Pool</code><!-- for now -->.</p>" +
+		"<script>if (a < b) {}</script><p title=\"a > b\">x</p></p>" + strings.Repeat("<p>pad</p>", 1000)
+
+	want, wantErr := Convert(s)
+	for _, r := range []io.Reader{
+		strings.NewReader(s),
+		iotest.OneByteReader(strings.NewReader(s)),
+		iotest.HalfReader(strings.NewReader(s)),
+	} {
+		md, err := ConvertReader(r)
+		if md != want || err == nil || err.Error() != wantErr.Error() {
+			t.Errorf("got %q, %v, want %q, %v", md, err, want, wantErr)
+		}
+	}
+
+	errRead := errors.New("connection reset")
+	if _, err := ConvertReader(iotest.ErrReader(errRead)); err != errRead {
+		t.Errorf("got error %v, want %v", err, errRead)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+)
+
+// ErrNoTable is returned by ConvertTableCSV when html holds no table.
+var ErrNoTable = errors.New("html2md: no table found")
+
+// ConvertTableCSV converts the first table in html into CSV. Every cell
+// becomes a field holding its plain text, and short rows are padded so all
+// records have the same number of fields.
+func ConvertTableCSV(html string) (string, error) {
+	table := find(parse(html), "table")
+	if table == nil {
+		return "", ErrNoTable
+	}
+
+	var (
+		records [][]string
+		cols    int
+	)
+
+	for _, tr := range tableRows(table) {
+		var record []string
+		for _, cell := range tr.children {
+			if cell.isElement("td", "th") {
+				record = append(record, strings.Join(strings.Fields(cell.text()), " "))
+			}
+		}
+		if len(record) > cols {
+			cols = len(record)
+		}
+		records = append(records, record)
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	for _, record := range records {
+		for len(record) < cols {
+			record = append(record, "")
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+
+	return b.String(), w.Error()
+}
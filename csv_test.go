@@ -0,0 +1,21 @@
+package html2md
+
+import (
+	"testing"
+)
+
+func TestConvertTableCSV(t *testing.T) {
+	const s = `<h2>Allocations</h2><table><thead><tr><th>Method</th><th>Note</th></tr></thead>` +
+		`<tbody><tr><td><code>Format</code></td><td>returns a "string", allocates</td></tr>` +
+		`<tr><td>AppendFormat</td></tr></tbody></table>`
+
+	want := "Method,Note\nFormat,\"returns a \"\"string\"\", allocates\"\nAppendFormat,\n"
+	csv, err := ConvertTableCSV(s)
+	if err != nil || csv != want {
+		t.Errorf("got %q, %v, want %q", csv, err, want)
+	}
+
+	if _, err := ConvertTableCSV("<p>no table</p>"); err != ErrNoTable {
+		t.Errorf("got error %v, want %v", err, ErrNoTable)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"strconv"
+	"strings"
+)
+
+// footnote is a note referenced by a <sup><a href="#id">n</a></sup> marker,
+// where id belongs to the <li> holding the note.
+type footnote struct {
+	label string
+	item  *node
+}
+
+type footnotes struct {
+	refs     map[*node]*footnote
+	items    map[*node]bool
+	backrefs map[string]bool
+	order    []*footnote
+}
+
+// scanFootnotes collects the footnote markers under root whose target is a
+// list item. Markers pointing anywhere else are left for normal conversion.
+func scanFootnotes(root *node) *footnotes {
+	var (
+		ids   = make(map[string]*node)
+		sups  []*node
+		notes = &footnotes{
+			refs:     make(map[*node]*footnote),
+			items:    make(map[*node]bool),
+			backrefs: make(map[string]bool),
+		}
+		walk func(n *node)
+	)
+
+	walk = func(n *node) {
+		if id := n.attr("id"); id != "" && ids[id] == nil {
+			ids[id] = n
+		}
+		if n.isElement("sup") {
+			sups = append(sups, n)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	byItem := make(map[*node]*footnote)
+	for _, sup := range sups {
+		a := onlyChild(sup, "a")
+		if a == nil || !strings.HasPrefix(a.attr("href"), "#") {
+			continue
+		}
+
+		item := ids[a.attr("href")[1:]]
+		if item == nil || !item.isElement("li") {
+			continue
+		}
+
+		fn := byItem[item]
+		if fn == nil {
+			fn = &footnote{label: footnoteLabel(a.text(), len(notes.order)+1), item: item}
+			byItem[item] = fn
+			notes.items[item] = true
+			notes.order = append(notes.order, fn)
+		}
+		notes.refs[sup] = fn
+
+		for _, id := range []string{sup.attr("id"), a.attr("id")} {
+			if id != "" {
+				notes.backrefs[id] = true
+			}
+		}
+	}
+
+	return notes
+}
+
+// footnoteLabel returns the marker text as a label when it can be one, and
+// the note number otherwise.
+func footnoteLabel(text string, n int) string {
+	label := strings.Trim(strings.TrimSpace(text), "[]")
+	if label == "" || strings.IndexFunc(label, func(c rune) bool {
+		return !(c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z')
+	}) != -1 {
+		return strconv.Itoa(n)
+	}
+	return label
+}
+
+// [^1]
+func (r *renderer) mdfootnoteref(fn *footnote) string {
+	return "[^" + fn.label + "]"
+}
+
+// [^1]: text
+func (r *renderer) mdfootnotes() string {
+	defs := make([]string, 0, len(r.notes.order))
+	for _, fn := range r.notes.order {
+		lines := strings.Split(r.blocks(fn.item), "\n")
+		for i, line := range lines {
+			if i > 0 && line != "" {
+				lines[i] = "    " + line
+			}
+		}
+		defs = append(defs, "[^"+fn.label+"]: "+strings.Join(lines, "\n"))
+	}
+	return strings.Join(defs, "\n")
+}
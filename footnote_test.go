@@ -0,0 +1,20 @@
+package html2md
+
+import (
+	"testing"
+)
+
+func TestFootnotes(t *testing.T) {
+	const s = `<p>Time has a pointer<sup id="fnref1"><a href="#fn1">1</a></sup> and KSUID none` +
+		`<sup><a href="#fn2">[2]</a></sup>. See also<sup><a href="#nowhere">3</a></sup>.</p>` +
+		`<hr><ol class="footnotes"><li id="fn1"><p>Changed in Go 1.9. <a href="#fnref1">↩</a></p></li>` +
+		`<li id="fn2"><p>A [20]byte.</p><p>No pointers.</p></li></ol>`
+
+	want := "Time has a pointer[^1] and KSUID none[^2]. See also[3](#nowhere).\n\n" +
+		"* * *\n\n" +
+		"[^1]: Changed in Go 1.9.\n" +
+		"[^2]: A \\[20\\]byte.\n\n    No pointers.\n"
+	if md := convert(s); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
@@ -30,264 +30,2008 @@
 package html2md
 
 import (
-	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
-	replaceMap = map[string]string{"&#x27;": "'", "&amp;": "&", "&quot;": "\"", "&lt;": "<", "&gt;": ">"}
+	blockElements = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "caption": true,
+		"center": true, "dd": true, "details": true, "dialog": true, "div": true, "dl": true, "dt": true,
+		"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true, "h1": true,
+		"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "head": true, "header": true,
+		"hgroup": true, "hr": true, "html": true, "li": true, "main": true, "menu": true, "nav": true,
+		"noscript": true, "ol": true, "p": true, "pre": true, "script": true, "section": true, "style": true,
+		"summary": true, "table": true, "tbody": true, "td": true, "template": true, "tfoot": true, "th": true,
+		"thead": true, "tr": true, "ul": true,
+	}
+
+	// skipElements never produce any md.
+	skipElements = map[string]bool{"head": true, "script": true, "style": true, "template": true}
+
+	// zeroWidthRemover drops invisible characters that only get in the way.
+	// Joiners are kept as emoji sequences and some scripts depend on them.
+	zeroWidthRemover = strings.NewReplacer("\u200b", "", "\u2060", "", "\ufeff", "")
+
+	// quoteStraightener turns typographic quotes, dashes and ellipses into
+	// their ASCII forms.
+	quoteStraightener = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "“", `"`, "”", `"`, "„", `"`, "‟", `"`,
+		"–", "-", "—", "--", "…", "...",
+	)
+
+	// quoteMarks hold the opening and closing marks of a <q> for each
+	// QuoteStyle, followed by those of a <q> nested in it.
+	quoteMarks = map[QuoteStyle][4]string{
+		QuoteStraight:   {`"`, `"`, "'", "'"},
+		QuoteCurly:      {"“", "”", "‘", "’"},
+		QuoteGuillemets: {"«", "»", "“", "”"},
+	}
+
+	// spaceCollapser matches the runs of whitespace in text that render as a
+	// single space. No-break spaces are not among them.
+	spaceCollapser = regexp.MustCompile(`[ \t\n\r\f]+`)
+
+	// entityLike matches anything md renderers decode as a character
+	// reference.
+	entityLike = regexp.MustCompile(`&(#[0-9]{1,7};|#[xX][0-9a-fA-F]{1,6};|[A-Za-z][A-Za-z0-9]*;)`)
+
+	// charRef matches the character references left in code that was
+	// encoded twice.
+	charRef = regexp.MustCompile(`&(lt|gt|amp|quot|apos|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+	// lineAnchor matches the ids of the elements GitHub and GitLab wrap every
+	// line of code in.
+	lineAnchor = regexp.MustCompile(`^LC[0-9]+$`)
+
+	// shortcode matches an emoji shortcode like :smile:.
+	shortcode = regexp.MustCompile(`^:[a-z0-9_+-]+:$`)
+
+	altEscaper = strings.NewReplacer("[", "\\[", "]", "\\]")
+
+	destinationEncoder = strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+	destinationEscaper = strings.NewReplacer("\n", "%0A", "<", "\\<", ">", "\\>")
 )
 
 // ParseHTMLtoMD parses html into md and returns md. Functions like
 // func(err interface {}) can be passed in to deal with panic.
+//
+// Convert reports problems as an error instead and is preferred, and a
+// Converter takes options.
 func ParseHTMLtoMD(s string, PanicHandle func(err interface{})) string {
-	defer func() {
-		if err := recover(); err != nil {
-			if PanicHandle != nil {
-				PanicHandle(err)
-			} else {
-				panic(err)
+	md, err := NewConverter().Convert(s)
+	if e, ok := err.(*Error); ok && e.panic != nil {
+		if PanicHandle == nil {
+			panic(e.panic)
+		}
+		PanicHandle(e.panic)
+	}
+	return md
+}
+
+// renderer converts a parsed node tree into md.
+type renderer struct {
+	opts  *options
+	root  *node
+	slugs map[string]string
+	notes *footnotes
+	refs  *linkRefs
+
+	// midLine is set while converting inline content that does not start
+	// a line of md, where block markers need no escaping.
+	midLine bool
+
+	// out receives the md as it is converted when writing to an io.Writer,
+	// from the blocks of stream and the containers within it.
+	out    *blockWriter
+	stream *node
+}
+
+func newRenderer(opts *options, root *node) *renderer {
+	splitEmphasis(root)
+	mergeEmphasis(root)
+	r := &renderer{opts: opts, root: root, notes: scanFootnotes(root)}
+	if opts.headingSlugs {
+		r.slugs = headingSlugs(root)
+	}
+	return r
+}
+
+// document converts the whole tree, followed by the footnotes.
+func (r *renderer) document() string {
+	blocks := r.blockList(r.root)
+	if len(r.notes.order) > 0 {
+		blocks = append(blocks, r.mdfootnotes())
+	}
+	if r.refs != nil {
+		blocks = append(blocks, r.mdlinkrefs())
+	}
+
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	md := strings.Join(blocks, "\n\n") + "\n"
+	if r.opts.wrapFence {
+		fence := strings.Repeat("`", longestRun(md, '`')+1)
+		if len(fence) < 3 {
+			fence = "```"
+		}
+		md = fence + r.opts.wrapLang + "\n" + md + fence + "\n"
+	}
+	return md
+}
+
+// write converts the whole tree like document does, writing the md to w as
+// it goes. It stops at the first error from w.
+func (r *renderer) write(w io.Writer) error {
+	r.out = &blockWriter{w: w}
+	if r.opts.wrapFence {
+		// The fence length depends on the whole md.
+		if md := r.document(); md != "" {
+			r.out.write(strings.TrimSuffix(md, "\n"))
+		}
+	} else {
+		r.stream = r.root
+		r.out.write(r.blockList(r.root)...)
+		if len(r.notes.order) > 0 {
+			r.out.write(r.mdfootnotes())
+		}
+		if r.refs != nil {
+			r.out.write(r.mdlinkrefs())
+		}
+	}
+
+	if r.out.n > 0 {
+		r.out.writeString("\n")
+	}
+	return r.out.err
+}
+
+// blocks converts the children of n into md blocks separated by blank lines.
+// Runs of inline children are gathered into paragraphs.
+func (r *renderer) blocks(n *node) string {
+	return strings.Join(r.blockList(n), "\n\n")
+}
+
+func (r *renderer) blockList(n *node) []string {
+	return r.blockNodes(n, n.children)
+}
+
+// blockNodes converts nodes, children of parent, into md blocks.
+func (r *renderer) blockNodes(parent *node, nodes []*node) []string {
+	var (
+		blocks         []string
+		inline         []*node
+		quote, heading = -1, -1
+	)
+
+	// add appends the block s converted from an element named tag, or from
+	// inline content when tag is empty.
+	add := func(s, tag string) {
+		if s == "" {
+			return
+		}
+
+		last := len(blocks) - 1
+		switch {
+		case last >= 0 && heading == last && !r.opts.headingBlank:
+			blocks[last] += "\n" + s
+		case last >= 0 && quote == last && tag == "blockquote" && r.opts.mergeQuotes:
+			blocks[last] += "\n>\n" + s
+		default:
+			blocks = append(blocks, s)
+		}
+
+		last, heading, quote = len(blocks)-1, -1, -1
+		switch tag {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			heading = last
+		case "blockquote":
+			quote = last
+		}
+	}
+
+	flush := func() {
+		add(r.paragraph(parent, r.inlines(inline)), "")
+		inline = inline[:0]
+	}
+
+	// When writing to an io.Writer, blocks of the containers being streamed
+	// are written out as soon as no later block can merge into them.
+	var written int
+	if r.out != nil && parent == r.stream {
+		add = r.streamed(add, &blocks, &written)
+	}
+
+	level, sections := 0, -1
+	for _, child := range nodes {
+		if r.out != nil && r.out.err != nil {
+			return nil
+		}
+
+		if !isBlock(child) {
+			inline = append(inline, child)
+			continue
+		}
+
+		flush()
+		if h := headingLevel(child); h > 0 {
+			if r.opts.demoteHeadings && heading >= 0 && heading == len(blocks)-1 && h <= level {
+				h = level + 1
+				if h > 6 {
+					h = 6
+				}
+			}
+			level = h
+			add(r.heading(child, h), child.tag)
+			continue
+		}
+
+		// sections is the number of blocks up to the end of the last
+		// section, or -1 if there is none or something came after it.
+		section := r.opts.sectionBreaks && child.isElement("section") && !child.inside("section")
+		if section && sections >= 0 && sections == len(blocks) {
+			add("* * *", "hr")
+		}
+
+		leaf, ok := r.leafBlock(child)
+		if !ok && parent == r.stream && r.out != nil && (heading < 0 || r.opts.headingBlank) {
+			r.out.write(blocks[written:]...)
+			written = len(blocks)
+			n := r.out.n
+			r.stream = child
+			leaf = r.blocks(child)
+			r.stream = parent
+
+			// Blocks the container wrote out can no longer be merged into,
+			// and come after the last section.
+			if r.out.n > n {
+				quote, heading, sections = -1, -1, -1
+				if section {
+					sections = len(blocks)
+				}
+			}
+		} else if !ok {
+			leaf = r.blocks(child)
+		}
+		before := len(blocks)
+		add(leaf, child.tag)
+		if section && len(blocks) > before {
+			sections = len(blocks)
+		}
+	}
+	flush()
+
+	return blocks[written:]
+}
+
+// streamed wraps add to write every block but the last to r.out once a new
+// block is added, counting them in written.
+func (r *renderer) streamed(add func(s, tag string), blocks *[]string, written *int) func(s, tag string) {
+	return func(s, tag string) {
+		add(s, tag)
+		if last := len(*blocks) - 1; last > *written {
+			r.out.write((*blocks)[*written:last]...)
+			*written = last
+		}
+	}
+}
+
+// leafBlock converts the block n if it has an md form of its own. It
+// returns false for containers like <div>, which only hold other blocks.
+func (r *renderer) leafBlock(n *node) (string, bool) {
+	if skipElements[n.tag] {
+		return "", true
+	}
+
+	switch n.tag {
+	case "p":
+		return r.mdp(n), true
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return r.mdh(n), true
+	case "blockquote":
+		return r.mdblockquote(n), true
+	case "ul":
+		return r.mdul(n), true
+	case "ol":
+		return r.mdol(n), true
+	case "pre":
+		return r.mdpre(n), true
+	case "table":
+		if role := n.attr("role"); role == "presentation" || role == "none" {
+			return r.mdlayout(n), true
+		}
+		if r.opts.complexTables && isComplexTable(n) {
+			return n.outerHTML(), true
+		}
+		return r.mdtable(n), true
+	case "figure":
+		return r.mdfigure(n), true
+	case "hr":
+		return "* * *", true
+	case "dl":
+		if r.opts.definitions != DefinitionText {
+			return r.mddl(n), true
+		}
+	case "fieldset":
+		if r.opts.choiceLists {
+			if choices := r.mdchoices(n); choices != "" {
+				return choices, true
 			}
 		}
-	}()
+	case "details":
+		if r.opts.keepDetails {
+			return r.mddetails(n), true
+		}
+	}
 
-	return parse(s)
+	return "", false
 }
 
-func parse(s string) string {
-	for {
-		if strings.Index(s, "<") == -1 {
-			for k, v := range replaceMap {
-				strings.Replace(s, k, v, -1)
+// inlines converts nodes into a single run of text. Whitespace between
+// them collapses into one space, as it does in a browser, and spaces next
+// to a line break are dropped.
+func (r *renderer) inlines(nodes []*node) string {
+	var (
+		b       []byte
+		midLine = r.midLine
+	)
+	defer func() { r.midLine = midLine }()
+
+	for _, n := range nodes {
+		r.midLine = continuesLine(b, midLine)
+		s := r.inline(n)
+		switch {
+		case n.isElement("br"):
+			for len(b) > 0 && b[len(b)-1] == ' ' {
+				b = b[:len(b)-1]
 			}
-			return s
+		case len(b) > 0 && (b[len(b)-1] == ' ' || b[len(b)-1] == '\n'):
+			s = strings.TrimLeft(s, " ")
 		}
+		b = append(b, s...)
+	}
+	return string(b)
+}
 
-		text := strings.SplitN(s, "<", 2)
-		todo := strings.SplitN(text[1], ">", 2)
-		attrIndex := strings.Index(todo[0], " ")
-		if attrIndex == -1 {
-			switch todo[0] {
-			case "p":
-				return text[0] + mdp(todo[1])
-			case "code":
-				return text[0] + mdcode(todo[1])
-			case "em":
-				return text[0] + mdem(todo[1])
-			case "strong":
-				return text[0] + mdstrong(todo[1])
-			case "blockquote":
-				return text[0] + mdblockquote(todo[1])
-			case "ul":
-				return text[0] + mdul(todo[1])
-			case "ol":
-				return text[0] + mdol(todo[1])
-			case "u":
-				return text[0] + mdu(todo[1])
-			case "del":
-				return text[0] + mddel(todo[1])
-			case "figure":
-				return text[0] + mdfigure(todo[0][attrIndex+1:]+todo[1])
-			}
-		}
-
-		if todo[0][:1] == "h" {
-			return text[0] + mdh(todo[0][1:2]+todo[1])
-		}
-
-		switch todo[0][:attrIndex] {
-		case "pre":
-			return text[0] + mdpre(todo[0][attrIndex+1:]+todo[1])
-		case "a":
-			return text[0] + mda(todo[0][attrIndex+1:]+">"+todo[1])
+func (r *renderer) inline(n *node) string {
+	if n.typ == textNode {
+		text := spaceCollapser.ReplaceAllString(n.data, " ")
+		if r.opts.escape {
+			text = escape(text, !r.midLine)
 		}
+		return r.text(text)
 	}
+
+	if skipElements[n.tag] {
+		return ""
+	}
+
+	switch n.tag {
+	case "code":
+		return r.mdcode(n)
+	case "em", "i":
+		return r.mdem(n)
+	case "strong", "b":
+		return r.mdstrong(n)
+	case "u":
+		return r.mdu(n)
+	case "del", "s", "strike":
+		return r.mddel(n)
+	case "q":
+		return r.mdq(n)
+	case "ins":
+		return r.mdins(n)
+	case "mark":
+		return r.mdmark(n)
+	case "svg":
+		return r.mdsvg(n)
+	case "a":
+		return r.mda(n)
+	case "img":
+		return r.mdimg(n)
+	case "br":
+		return r.mdbr(n)
+	case "sup":
+		if fn := r.notes.refs[n]; fn != nil {
+			return r.mdfootnoteref(fn)
+		}
+		return r.mdscript(n, "^")
+	case "sub":
+		return r.mdscript(n, "~")
+	case "span":
+		if r.opts.mathClass != "" && n.hasClass(r.opts.mathClass) {
+			return r.mdmath(n)
+		}
+		if r.opts.langSpans && n.attr("lang") != "" {
+			return r.mdlang(n)
+		}
+	}
+
+	if r.opts.styles && n.hasAttr("style") {
+		return r.mdstyle(n)
+	}
+	return r.inlines(n.children)
+}
+
+// text converts the content of a text node.
+func (r *renderer) text(s string) string {
+	if r.opts.zeroWidth {
+		s = zeroWidthRemover.Replace(s)
+	}
+	if r.opts.straightQuotes {
+		s = quoteStraightener.Replace(s)
+	}
+	// The text is decoded already, so what still reads as a character
+	// reference came encoded and must not be decoded again.
+	return entityLike.ReplaceAllString(s, "&amp;$1")
 }
 
 // \n
-func mdp(s string) string {
-	text := strings.SplitN(s, "</p>", 2)
-	if len(text) == 1 {
-		return "\n" + parse(text[0]) + "\n"
+func (r *renderer) mdp(n *node) string {
+	// The parser ends a paragraph where most blocks start, but some can
+	// still end up inside one. They are kept apart from its text.
+	for _, child := range n.children {
+		if isBlock(child) {
+			return r.blocks(n)
+		}
+	}
+	if r.opts.spacers && onlyChild(n, "br") != nil {
+		return "&nbsp;"
+	}
+	return r.paragraph(n.parent, r.inlines(n.children))
+}
+
+// paragraph trims the converted paragraph s found in parent and applies the
+// paragraph prefix.
+func (r *renderer) paragraph(parent *node, s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || r.opts.paraPrefix == "" {
+		return s
+	}
+
+	for n := parent; n != nil; n = n.parent {
+		if n.isElement("li", "blockquote", "td", "th") {
+			return s
+		}
 	}
-	return "\n" + parse(text[0]) + "\n" + parse(text[1])
+
+	return r.opts.paraPrefix + strings.Replace(s, "\n", "\n"+r.opts.paraPrefix, -1)
 }
 
 // `text`
-func mdcode(s string) string {
-	text := strings.SplitN(s, "</code>", 2)
-	if len(text) == 1 {
-		return parse(" `" + text[0] + "` ")
+func (r *renderer) mdcode(n *node) string {
+	if r.opts.keepAttrs && n.attr("class") != "" {
+		return n.startTag() + html.EscapeString(n.text()) + "</code>"
+	}
+	text := n.text()
+	if strings.TrimSpace(text) == "" && !r.opts.blankCode {
+		return spaceCollapser.ReplaceAllString(text, " ")
 	}
-	return parse(" `"+text[0]+"` ") + parse(text[1])
+	if text != "" {
+		return codeSpan(text)
+	}
+	return ""
+}
+
+// codeSpan returns text as a code span. The backticks around it are fewer
+// or more than in any run of backticks within, and spaces are added where
+// md would otherwise take a backtick or space of the text as part of the
+// delimiters.
+func codeSpan(text string) string {
+	runs := make(map[int]bool)
+	for _, run := range strings.FieldsFunc(text, func(r rune) bool { return r != '`' }) {
+		runs[len(run)] = true
+	}
+	n := 1
+	for runs[n] {
+		n++
+	}
+
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") ||
+		strings.HasPrefix(text, " ") && strings.HasSuffix(text, " ") && strings.Trim(text, " ") != "" {
+		text = " " + text + " "
+	}
+	ticks := strings.Repeat("`", n)
+	return ticks + text + ticks
 }
 
 // *text*
-func mdem(s string) string {
-	text := strings.SplitN(s, "</em>", 2)
-	if len(text) == 1 {
-		return parse(" *" + text[0] + "* ")
+func (r *renderer) mdem(n *node) string {
+	// Nested emphasis has no md form of its own and would only produce
+	// stray markers.
+	if r.italicAncestor(n) {
+		return r.inlines(n.children)
+	}
+	return wrap(r.inlines(n.children), "*")
+}
+
+// ***text***
+func (r *renderer) mdstyle(n *node) string {
+	text := r.inlines(n.children)
+	if isBold(n.style("font-weight")) && !r.boldAncestor(n) {
+		text = wrap(text, "**")
+	}
+	if isItalic(n.style("font-style")) && !r.italicAncestor(n) {
+		text = wrap(text, "*")
+	}
+	if strings.Contains(n.style("text-decoration")+" "+n.style("text-decoration-line"), "line-through") {
+		text = r.strike(text, "del")
+	}
+	return text
+}
+
+// $latex$
+func (r *renderer) mdmath(n *node) string {
+	latex := n.attr("data-latex")
+	if latex == "" {
+		latex = n.text()
+	}
+
+	if latex = strings.TrimSpace(latex); latex == "" {
+		return ""
+	}
+	return "$" + latex + "$"
+}
+
+// hard line break
+func (r *renderer) mdbr(n *node) string {
+	// A break before or after all the content of its block, or in a table
+	// cell, which has to stay on one line, would only leave a stray marker.
+	if n.inside("td", "th") || !hasContent(n, -1) || !hasContent(n, 1) {
+		return "\n"
+	}
+	// An ATX heading ends with its line; only setext ones span lines.
+	if n.inside("h3", "h4", "h5", "h6") || r.opts.headingStyle != HeadingSetext && n.inside("h1", "h2") {
+		return " "
+	}
+	return r.hardBreak()
+}
+
+// hardBreak returns the line ending of a hard line break.
+func (r *renderer) hardBreak() string {
+	if r.opts.breakStyle == BreakBackslash {
+		return "\\\n"
+	}
+	return "  \n"
+}
+
+// hasContent reports whether anything but whitespace and breaks comes
+// before n, for dir -1, or after it, for dir 1, within its block.
+func hasContent(n *node, dir int) bool {
+	for ; n.parent != nil && !isBlock(n); n = n.parent {
+		i := 0
+		for i < len(n.parent.children) && n.parent.children[i] != n {
+			i++
+		}
+		for i += dir; 0 <= i && i < len(n.parent.children); i += dir {
+			switch sibling := n.parent.children[i]; {
+			case sibling.typ == textNode:
+				if strings.TrimSpace(sibling.data) != "" {
+					return true
+				}
+			case !sibling.isElement("br") && !skipElements[sibling.tag]:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// continuesLine reports whether md written after b continues a line with
+// content on it, given whether b itself does. Nodes that render nothing
+// leave the line as it was.
+func continuesLine(b []byte, midLine bool) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		switch b[i] {
+		case '\n':
+			return false
+		case ' ':
+		default:
+			return true
+		}
+	}
+	return midLine
+}
+
+// <span lang="...">text</span>
+func (r *renderer) mdlang(n *node) string {
+	text := r.inlines(n.children)
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	return `<span lang="` + html.EscapeString(n.attr("lang")) + `">` + text + "</span>"
+}
+
+// ^text^ or ~text~, written on one line so that it also fits in a table
+// cell.
+func (r *renderer) mdscript(n *node, mark string) string {
+	text := strings.Join(strings.Fields(r.inlines(n.children)), " ")
+	if text == "" {
+		return ""
 	}
-	return parse(" *"+text[0]+"* ") + parse(text[1])
+
+	switch r.opts.scripts {
+	case ScriptHTML:
+		return "<" + n.tag + ">" + text + "</" + n.tag + ">"
+	case ScriptCaret:
+		return mark + text + mark
+	}
+	return text
 }
 
 // **text**
-func mdstrong(s string) string {
-	text := strings.SplitN(s, "</strong>", 2)
-	if len(text) == 1 {
-		return parse(" **" + text[0] + "** ")
+func (r *renderer) mdstrong(n *node) string {
+	if r.boldAncestor(n) {
+		return r.inlines(n.children)
 	}
-	return parse(" **"+text[0]+"** ") + parse(text[1])
+	return wrap(r.inlines(n.children), "**")
 }
 
-// > text
-func mdblockquote(s string) string {
-	text := strings.SplitN(s, "</blockquote>", 2)
-	blockquote := strings.Replace(text[0], "<p>", "> ", -1)
-	blockquote = strings.Replace(blockquote, "</p>", "\n", -1)
-	if len(text) == 1 {
-		return parse(blockquote)
+// boldAncestor reports whether an ancestor of n already makes it bold, by
+// its tag or a recognized style.
+func (r *renderer) boldAncestor(n *node) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.isElement("strong", "b") || r.opts.styles && isBold(p.style("font-weight")) {
+			return true
+		}
 	}
-	return parse(blockquote) + parse(text[1])
+	return false
 }
 
-// *-+ text
-func mdul(s string) string {
+// italicAncestor reports whether an ancestor of n already makes it italic,
+// by its tag or a recognized style.
+func (r *renderer) italicAncestor(n *node) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.isElement("em", "i") || r.opts.styles && isItalic(p.style("font-style")) {
+			return true
+		}
+	}
+	return false
+}
+
+// > text
+func (r *renderer) mdblockquote(n *node) string {
 	var (
-		li    string
-		count = 1
-		text  = []string{"", s}
+		content     []*node
+		attribution string
 	)
 
-	for {
-		text = strings.SplitN(text[1], "</ul>", 2)
-		if strings.Index(text[0], "<ul>") == -1 {
-			count--
-			li += mdli(text[0], count)
+	// A <footer> or <cite> directly in the quote names its source. It goes
+	// on a line of its own at the end, after a dash.
+	for _, child := range n.children {
+		if child.isElement("footer", "cite") {
+			text := strings.Join(strings.Fields(r.inlines(child.children)), " ")
+			if text = strings.TrimLeft(text, "—―–- "); text != "" {
+				attribution = "— " + text
+			}
+			continue
+		}
+		content = append(content, child)
+	}
+
+	blocks := r.blockNodes(n, content)
+	if attribution != "" && len(blocks) > 0 {
+		blocks = append(blocks, attribution)
+	}
+	if cite := n.attr("cite"); r.opts.quoteCite && cite != "" && len(blocks) > 0 {
+		cite = r.resolve(cite, r.opts.baseURL)
+		if isAutolink(cite, cite) {
+			cite = "<" + cite + ">"
 		}
-		if count == 0 {
-			return li + parse(text[1])
+		blocks = append(blocks, "— "+cite)
+	}
+
+	lines := strings.Split(strings.Join(blocks, "\n\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
 		}
-		text = strings.SplitN(text[0]+"</ul>"+text[1], "<ul>", 2)
-		li += mdli(text[0], count)
-		count++
-		text = strings.SplitN(text[1], "</ul>", 2)
-		li += mdli(text[0], count)
-		count--
 	}
+	return strings.Join(lines, "\n")
 }
 
 // *-+ text
-func mdli(s string, n int) string {
-	var li string
-	for i := 1; i < n; i++ {
-		li += "  "
+func (r *renderer) mdul(n *node) string {
+	return r.list(n, func(int) string {
+		return "* "
+	})
+}
+
+// 1. text
+func (r *renderer) mdol(n *node) string {
+	start, err := strconv.Atoi(strings.TrimSpace(n.attr("start")))
+	if err != nil || start < 0 {
+		start = 1
 	}
 
-	s = strings.Replace(s, "<li>", "", -1)
-	s = strings.Replace(s, "</li>", "", -1)
-	s = strings.Replace(s, "<p>", li+"* ", -1)
-	s = strings.Replace(s, "</p>", "\n", -1)
+	if r.opts.reversed && n.hasAttr("reversed") {
+		if !n.hasAttr("start") || err != nil {
+			start = r.itemCount(n)
+		}
+		return r.list(n, func(i int) string {
+			return r.olMarker(n, start-i)
+		})
+	}
 
-	return parse(s)
+	return r.list(n, func(i int) string {
+		return r.olMarker(n, start+i)
+	})
 }
 
-// 1. text
-func mdol(s string) string {
-	var str string
-	text := strings.SplitN(s, "</ol>", 2)
-	ol := strings.Split(strings.Replace(text[0], "</p></li>", "", -1), "<li><p>")
-	for k, v := range ol[1:] {
-		str += fmt.Sprintf("%d. %s\n", k+1, v)
-	}
-	if len(text) == 1 {
-		return parse(str)
+// olMarker returns the marker of the item numbered num in the <ol> n.
+func (r *renderer) olMarker(n *node, num int) string {
+	marker := strconv.Itoa(num)
+	if r.opts.typeMarkers && num > 0 {
+		switch n.attr("type") {
+		case "a":
+			marker = strings.ToLower(letters(num))
+		case "A":
+			marker = letters(num)
+		case "i":
+			marker = strings.ToLower(roman(num))
+		case "I":
+			marker = roman(num)
+		}
 	}
-	return parse(str) + parse(text[1])
+	return marker + r.opts.olDelimiter + " "
 }
 
-// <u>text</u>
-func mdu(s string) string {
-	text := strings.SplitN(s, "</u>", 2)
-	if len(text) == 1 {
-		return "<u>" + text[0] + "</u>"
+// list converts the <li> children of n, taking each item marker from marker.
+// Items are separated by blank lines once any item holds several blocks, and
+// items with no content are left out.
+func (r *renderer) list(n *node, marker func(i int) string) string {
+	var (
+		items []string
+		loose bool
+	)
+
+	for _, child := range n.children {
+		if !child.isElement("li") || r.notes.items[child] {
+			continue
+		}
+
+		item, multi := r.mdli(child, marker(len(items)))
+		if item == "" {
+			continue
+		}
+		items = append(items, item)
+		loose = loose || multi
+	}
+
+	switch r.opts.listSpacing {
+	case ListTight:
+		loose = false
+	case ListLoose:
+		loose = true
+	}
+
+	if loose {
+		return strings.Join(items, "\n\n")
 	}
-	return "<u>" + parse(text[0]) + "</u>" + parse(text[1])
+	return strings.Join(items, "\n")
 }
 
-// ~~text~~
-func mddel(s string) string {
-	text := strings.SplitN(s, "</del>", 2)
-	if len(text) == 1 {
-		return parse(" ~~" + text[0] + "~~ ")
+// itemCount returns the number of items list writes for n, which has to
+// convert them to tell the empty ones it leaves out.
+func (r *renderer) itemCount(n *node) int {
+	count := 0
+	for _, child := range n.children {
+		if !child.isElement("li") || r.notes.items[child] {
+			continue
+		}
+		if item, _ := r.mdli(child, ""); item != "" {
+			count++
+		}
 	}
-	return parse(" ~~"+text[0]+"~~ ") + parse(text[1])
+	return count
 }
 
-// ![text](url)
-func mdfigure(s string) string {
-	text := strings.SplitN(s, "</figure>", 2)
-	if len(text[0]) > 13 {
-		img := strings.SplitN(text[0], "\"", 3)[1]
-		if len(text) == 1 {
-			return "![](" + img + ")"
+// *-+ text
+func (r *renderer) mdli(n *node, marker string) (string, bool) {
+	var (
+		b     strings.Builder
+		multi bool
+		run   []*node
+	)
+
+	// A nested list follows the block before it without a blank line.
+	write := func(block string, list bool) {
+		if block == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+			if !list {
+				b.WriteString("\n")
+				multi = true
+			}
+		}
+		b.WriteString(block)
+	}
+	flush := func() {
+		for _, block := range r.blockNodes(n, run) {
+			write(block, false)
+		}
+		run = nil
+	}
+
+	for _, child := range n.children {
+		if !child.isElement("ul", "ol") {
+			run = append(run, child)
+			continue
 		}
-		return "![](" + img + ")\n" + parse(text[1])
+		flush()
+		list, _ := r.leafBlock(child)
+		write(list, true)
 	}
-	return parse(text[1])
+	flush()
+
+	if b.Len() == 0 {
+		return "", false
+	}
+	if pad := r.opts.listIndent - len(marker); pad > 0 {
+		marker += strings.Repeat(" ", pad)
+	}
+	return indent(b.String(), marker), multi
 }
 
-// # text
-func mdh(s string) string {
-	var header = "\n#"
-	text := strings.SplitN(s, "</h", 2)
-	h, _ := strconv.Atoi(text[0][:1])
-	for len(header) < h {
-		header += "#"
+// term
+// : definition
+func (r *renderer) mddl(n *node) string {
+	var (
+		groups []string
+		terms  []string
+		defs   []string
+	)
+
+	flush := func() {
+		if len(terms) == 0 && len(defs) == 0 {
+			return
+		}
+		groups = append(groups, r.definitions(terms, defs))
+		terms, defs = nil, nil
 	}
-	if len(text) == 1 {
-		return parse(header + " " + text[0][1:])
+
+	for _, child := range n.children {
+		switch {
+		case child.isElement("dt"):
+			if len(defs) > 0 {
+				flush()
+			}
+			if term := strings.TrimSpace(r.inlines(child.children)); term != "" {
+				terms = append(terms, term)
+			}
+		case child.isElement("dd"):
+			if def := r.blocks(child); def != "" {
+				defs = append(defs, def)
+			}
+		}
 	}
-	return parse(header+" "+text[0][1:]) + "\n" + parse(strings.SplitN(text[1], ">", 2)[1])
+	flush()
+
+	return strings.Join(groups, "\n\n")
 }
 
-// ```language```
-func mdpre(s string) string {
-	text := strings.SplitN(s, "</pre>", 2)
-	language := strings.SplitN(text[0], "\"", 3)
-	start := strings.Index(language[2], "<code>")
-	end := strings.Index(language[2], "</code>")
-	if len(text) == 1 {
-		return "\n```" + language[1] + "\n" + language[2][start+6:end] + "\n```\n"
+// definitions converts a group of terms sharing the definitions defs.
+func (r *renderer) definitions(terms, defs []string) string {
+	var lines []string
+	switch r.opts.definitions {
+	case DefinitionBold:
+		var blocks []string
+		for _, term := range terms {
+			lines = append(lines, wrap(term, "**"))
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, strings.Join(lines, "  \n"))
+		}
+		for _, def := range defs {
+			blocks = append(blocks, indent(def, "  "))
+		}
+		return strings.Join(blocks, "\n\n")
+	case DefinitionSingle:
+		lines = terms
+		if len(defs) > 0 {
+			lines = append(lines, indent(strings.Join(defs, "  \n"), ": "))
+		}
+	default:
+		lines = terms
+		for _, def := range defs {
+			lines = append(lines, indent(def, ":   "))
+		}
 	}
-	return "\n```" + language[1] + "\n" + language[2][start+6:end] + "\n```\n" + parse(text[1])
+	return strings.Join(lines, "\n")
 }
 
-// [text](url)
-func mda(s string) string {
-	text := strings.SplitN(s, "\">", 2)
-	if text[1][:4] != "<a h" {
-		text := strings.SplitN(s, "</a>", 2)
-		a := strings.SplitN(text[0], "\"", 3)
-		if parse(a[2][1:]) == "" {
-			return parse(text[1])
-		} else if len(text) == 1 {
-			return "[" + parse(a[2][1:]) + "]" + "(" + a[1] + ")"
+// * [x] label
+func (r *renderer) mdchoices(n *node) string {
+	var (
+		inputs []*node
+		labels = make(map[string]*node)
+		walk   func(*node)
+	)
+
+	walk = func(n *node) {
+		switch {
+		case n.isElement("input"):
+			if t := strings.ToLower(n.attr("type")); t == "radio" || t == "checkbox" {
+				inputs = append(inputs, n)
+			}
+		case n.isElement("label"):
+			if id := n.attr("for"); id != "" {
+				labels[id] = n
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	if len(inputs) == 0 {
+		return ""
+	}
+
+	var blocks, items []string
+	if legend := childElements(n, "legend"); len(legend) > 0 {
+		if text := strings.TrimSpace(r.inlines(legend[0].children)); text != "" {
+			blocks = append(blocks, text)
+		}
+	}
+
+	for _, input := range inputs {
+		var text string
+		switch label := labels[input.attr("id")]; {
+		case label != nil:
+			text = r.inlines(label.children)
+		case input.parent.isElement("label"):
+			text = r.inlines(input.parent.children)
+		default:
+			text = input.attr("value")
+		}
+
+		mark := "[ ] "
+		if input.hasAttr("checked") {
+			mark = "[x] "
 		}
-		return "[" + parse(a[2][1:]) + "]" + "(" + a[1] + ")" + parse(text[1])
+		items = append(items, "* "+mark+strings.Join(strings.Fields(text), " "))
 	}
 
-	text = strings.SplitN(s, "</a>", 2)
-	counter := strings.Count(text[0], "<a href=\"")
-	text = strings.SplitN(s, "<a ", counter+1)
+	return strings.Join(append(blocks, strings.Join(items, "\n")), "\n\n")
+}
+
+// <details><summary>text</summary>
+func (r *renderer) mddetails(n *node) string {
+	var (
+		b       strings.Builder
+		content []*node
+	)
 
-	var a string
-	for i := 0; i < counter; i++ {
-		a += "</a>"
+	b.WriteString(n.startTag())
+	for _, child := range n.children {
+		if child.isElement("summary") {
+			b.WriteString("\n" + child.startTag() + strings.TrimSpace(r.inlines(child.children)) + "</summary>")
+			continue
+		}
+		content = append(content, child)
 	}
 
-	s = strings.Replace(text[counter], a, "", 1)
+	if blocks := r.blockNodes(n, content); len(blocks) > 0 {
+		b.WriteString("\n\n" + strings.Join(blocks, "\n\n") + "\n")
+	}
+	b.WriteString("\n</details>")
+	return b.String()
+}
 
-	return parse("<a " + s)
+// <u>text</u>
+func (r *renderer) mdu(n *node) string {
+	return "<u>" + r.inlines(n.children) + "</u>"
+}
+
+// "text"
+func (r *renderer) mdq(n *node) string {
+	i := 0
+	for p := n.parent; p != nil; p = p.parent {
+		if p.isElement("q") {
+			i = 2 - i
+		}
+	}
+	marks := quoteMarks[r.opts.quoteStyle]
+	return marks[i] + r.inlines(n.children) + marks[i+1]
+}
+
+// ~~text~~
+func (r *renderer) mddel(n *node) string {
+	return r.strike(r.inlines(n.children), n.tag)
+}
+
+// strike marks the converted text as struck through, falling back to the
+// html element tag when ~~ is not wanted.
+func (r *renderer) strike(text, tag string) string {
+	if r.opts.diffStyle == DiffHTML || !r.opts.strikethrough {
+		return "<" + tag + ">" + text + "</" + tag + ">"
+	}
+	return wrap(text, "~~")
+}
+
+// **text**
+func (r *renderer) mdins(n *node) string {
+	switch r.opts.diffStyle {
+	case DiffMarkers:
+		return wrap(r.inlines(n.children), "**")
+	case DiffHTML:
+		return "<ins>" + r.inlines(n.children) + "</ins>"
+	}
+	return r.inlines(n.children)
+}
+
+// ==text==
+func (r *renderer) mdmark(n *node) string {
+	text := r.inlines(n.children)
+	switch r.opts.highlight {
+	case HighlightEquals:
+		return wrap(text, "==")
+	case HighlightHTML:
+		return "<mark>" + text + "</mark>"
+	case HighlightBold:
+		return wrap(text, "**")
+	}
+	return text
+}
+
+// emoji converts the emoji image n into text.
+func (r *renderer) emoji(n *node) string {
+	if r.opts.emoji == EmojiShortcode {
+		for _, key := range []string{"title", "alt", "data-shortcode"} {
+			if code := n.attr(key); shortcode.MatchString(code) {
+				return code
+			}
+		}
+	}
+	return n.attr("alt")
+}
+
+// <svg> or ![title](data-src)
+func (r *renderer) mdsvg(n *node) string {
+	switch r.opts.svg {
+	case SVGHTML:
+		return n.outerHTML()
+	case SVGImage:
+		src := n.attr("data-src")
+		if src == "" {
+			return ""
+		}
+
+		alt := n.attr("aria-label")
+		if title := childElements(n, "title"); alt == "" && len(title) > 0 {
+			alt = strings.TrimSpace(title[0].text())
+		}
+		base := r.opts.imageBaseURL
+		if base == nil {
+			base = r.opts.baseURL
+		}
+		return "![" + alt + "](" + r.destination(src, base) + ")"
+	}
+	return ""
+}
+
+// ![text](url)
+func (r *renderer) mdimg(n *node) string {
+	alt := n.attr("alt")
+	if r.opts.dropDecorative && alt == "" && n.hasAttr("alt") {
+		return ""
+	}
+	if r.opts.emoji != EmojiImage && alt != "" && (n.hasClass("emoji") || n.hasClass("wp-smiley")) {
+		return r.emoji(n)
+	}
+
+	base := r.opts.imageBaseURL
+	if base == nil {
+		base = r.opts.baseURL
+	}
+	short := alt
+	if r.opts.maxAlt > 0 && utf8.RuneCountInString(alt) > r.opts.maxAlt {
+		short = strings.TrimRightFunc(string([]rune(alt)[:r.opts.maxAlt]), unicode.IsSpace) + "…"
+	}
+	short = altEscaper.Replace(strings.Join(strings.Fields(short), " "))
+	src := n.attr("src")
+	if r.opts.pictures && n.parent != nil && n.parent.isElement("picture") {
+		if source := pictureSource(n.parent, r.opts.pictureWidth); source != "" {
+			src = source
+		}
+	}
+	img := "![" + short + "](" + r.destination(src, base) + linkTitle(n.attr("title")) + ")"
+	// The caption goes on lines of its own, set off by hard breaks, which a
+	// link, a heading or a table cell could not hold.
+	caption := !n.inside("a", "h1", "h2", "h3", "h4", "h5", "h6", "td", "th")
+	if caption && r.opts.altCaption > 0 && utf8.RuneCountInString(alt) >= r.opts.altCaption {
+		img += r.hardBreak() + "*" + escape(strings.Join(strings.Fields(alt), " "), false) + "*"
+		if hasContent(n, 1) {
+			img += r.hardBreak()
+		}
+	}
+	return img
+}
+
+// pictureSource returns the first image url in the srcset of the first
+// <source> of the <picture> n whose media query matches a viewport width
+// px wide, or that has no media query when width is 0, or "" if none does.
+func pictureSource(n *node, width int) string {
+	for _, source := range childElements(n, "source") {
+		media := strings.TrimSpace(source.attr("media"))
+		if width == 0 && media != "" || width > 0 && media != "" && !mediaMatches(media, width) {
+			continue
+		}
+		if fields := strings.Fields(strings.Split(source.attr("srcset"), ",")[0]); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// mediaMatches reports whether the media query media, made of min-width and
+// max-width features in px joined by and, matches a viewport width px wide.
+// Other queries never match.
+func mediaMatches(media string, width int) bool {
+	for _, feature := range strings.Split(strings.ToLower(media), " and ") {
+		kv := strings.SplitN(strings.Trim(strings.TrimSpace(feature), "()"), ":", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		px, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(kv[1]), "px"))
+		if err != nil {
+			return false
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "min-width":
+			if width < px {
+				return false
+			}
+		case "max-width":
+			if width > px {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ![text](url)
+//
+// *caption*
+func (r *renderer) mdfigure(n *node) string {
+	var (
+		content []*node
+		caption string
+	)
+
+	for _, child := range n.children {
+		if child.isElement("figcaption") {
+			caption = wrap(strings.TrimSpace(r.inlines(child.children)), "*")
+			continue
+		}
+		content = append(content, child)
+	}
+
+	blocks := r.blockNodes(n, content)
+	if caption == "" {
+		return strings.Join(blocks, "\n\n")
+	}
+	if r.opts.captionStyle == CaptionLine && len(blocks) == 1 && !hasBlock(content) {
+		return blocks[0] + r.hardBreak() + caption
+	}
+	return strings.Join(append(blocks, caption), "\n\n")
+}
+
+// hasBlock reports whether any of nodes is a block element.
+func hasBlock(nodes []*node) bool {
+	for _, n := range nodes {
+		if isBlock(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// # text
+func (r *renderer) mdh(n *node) string {
+	return r.heading(n, headingLevel(n))
+}
+
+// heading converts the heading n at the given level.
+func (r *renderer) heading(n *node, level int) string {
+	text := strings.TrimSpace(r.inlines(n.children))
+	if text == "" {
+		return ""
+	}
+	var attrs string
+	if id := n.attr("id"); r.opts.headingIDs && id != "" {
+		attrs = " {#" + id + "}"
+	}
+	if r.opts.headingStyle == HeadingSetext && level <= 2 {
+		text += attrs
+		return text + "\n" + r.underline(text, level)
+	}
+	marks := strings.Repeat("#", level)
+	if r.opts.headingStyle == HeadingATXClosed {
+		return marks + " " + text + " " + marks + attrs
+	}
+	return marks + " " + text + attrs
+}
+
+// underline returns the Setext underline of a heading with the given text.
+func (r *renderer) underline(text string, level int) string {
+	mark := "="
+	if level == 2 {
+		mark = "-"
+	}
+
+	width := 3
+	if r.opts.setextWidth {
+		for _, line := range strings.Split(text, "\n") {
+			if w := displayWidth(line); w > width {
+				width = w
+			}
+		}
+	}
+	return strings.Repeat(mark, width)
+}
+
+// ```language```
+func (r *renderer) mdpre(n *node) string {
+	code := preText(n)
+	if r.opts.doubleCode && isDoubleEncoded(code) {
+		code = html.UnescapeString(code)
+	}
+	if r.opts.trimCode {
+		code = strings.TrimPrefix(code, "\n")
+	}
+	code = strings.TrimSuffix(code, "\n")
+	fence := strings.Repeat(r.opts.fence, 3)
+	if run := longestRun(code, r.opts.fence[0]); run >= 3 {
+		fence = strings.Repeat(r.opts.fence, run+1)
+	}
+	return fence + codeLanguage(n) + "\n" + code + "\n" + fence
+}
+
+// languageAttrs hold the language of a code block on the sites that name it.
+var languageAttrs = []string{"data-language", "data-lang", "data-syntax"}
+
+// codeLanguage returns the language named on the <pre> n or on the <code>
+// it holds, in lower case. The data attributes are preferred over classes
+// like language-go and lang-go.
+func codeLanguage(n *node) string {
+	elements := []*node{n}
+	if code := onlyChild(n, "code"); code != nil {
+		elements = append(elements, code)
+	}
+	for _, e := range elements {
+		for _, key := range languageAttrs {
+			if lang := strings.TrimSpace(e.attr(key)); lang != "" {
+				return strings.ToLower(lang)
+			}
+		}
+	}
+	for i := len(elements) - 1; i >= 0; i-- {
+		for _, class := range strings.Fields(elements[i].attr("class")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if lang := strings.TrimPrefix(class, prefix); lang != class && lang != "" {
+					return strings.ToLower(lang)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// | text |
+func (r *renderer) mdtable(n *node) string {
+	var (
+		trs   = tableRows(n)
+		rows  [][]string
+		align []string
+		cols  int
+	)
+
+	for _, tr := range trs {
+		row := r.cells(tr)
+		if len(row) > cols {
+			cols = len(row)
+		}
+		rows = append(rows, row)
+	}
+
+	// md tables have no caption, so it goes in a paragraph above the table
+	// wherever the html places it.
+	var caption string
+	if c := childElements(n, "caption"); len(c) > 0 {
+		caption = wrap(strings.Join(strings.Fields(r.inlines(c[0].children)), " "), "*")
+	}
+
+	if len(rows) == 0 {
+		return caption
+	}
+
+	// The first row is the header when it comes from <thead> or holds only
+	// <th>. Otherwise an empty header is added, as md tables need one.
+	header := trs[0].parent.isElement("thead") || isHeaderRow(trs[0])
+	for i := 0; i < cols; i++ {
+		align = append(align, columnAlign(trs, i))
+	}
+
+	lines := make([]string, 0, len(rows)+3)
+	if caption != "" {
+		lines = append(lines, caption, "")
+	}
+	if !header {
+		lines = append(lines, "|"+strings.Repeat("  |", cols), separator(align))
+	}
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		for j, cell := range row {
+			if cell == "" {
+				row[j] = r.opts.emptyCell
+			}
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 && header {
+			lines = append(lines, separator(align))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// cells converts the cells of the row n, each on a single line and with its
+// pipes escaped.
+func (r *renderer) cells(n *node) []string {
+	var cells []string
+	for _, child := range n.children {
+		if child.isElement("td", "th") {
+			text := strings.Join(strings.Fields(r.inlines(child.children)), " ")
+			cells = append(cells, strings.Replace(text, "|", "\\|", -1))
+		}
+	}
+	return cells
+}
+
+// mdlayout converts a table used for layout rather than data, as in emails,
+// into the content of its cells one block after another.
+func (r *renderer) mdlayout(n *node) string {
+	var blocks []string
+	for _, tr := range tableRows(n) {
+		for _, cell := range tr.children {
+			if cell.isElement("td", "th") {
+				blocks = append(blocks, r.blockList(cell)...)
+			}
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// [text](url)
+func (r *renderer) mda(n *node) string {
+	// The parser closes an open <a> when another starts, but one can still
+	// end up inside another through a table. md links cannot nest, so the
+	// inner one is reduced to its text.
+	if n.inside("a") {
+		return r.inlines(n.children)
+	}
+
+	if r.opts.codeAutolinks {
+		if code := onlyChild(n, "code"); code != nil && isAutolink(n.attr("href"), code.text()) {
+			return "<" + code.text() + ">"
+		}
+	}
+
+	var text string
+	if img := onlyChild(n, "img"); img != nil {
+		if text = r.mdimg(img); text == "" {
+			return ""
+		}
+	} else if text = r.inlines(n.children); strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	if n.hasAttr("download") {
+		switch r.opts.download {
+		case DownloadAnnotate:
+			text += " (download)"
+		case DownloadHTML:
+			return n.startTag() + text + "</a>"
+		}
+	}
+
+	href := strings.TrimSpace(n.attr("href"))
+	if href == "" {
+		if r.opts.emptyTarget == "" {
+			return text
+		}
+		href = r.opts.emptyTarget
+	}
+	if strings.HasPrefix(href, "#") {
+		if r.notes.backrefs[href[1:]] {
+			return ""
+		}
+		if r.opts.dropFragment {
+			return text
+		}
+		if slug, ok := r.slugs[href[1:]]; ok {
+			href = "#" + slug
+		}
+	}
+	if r.opts.plainTel && len(href) >= 4 && strings.EqualFold(href[:4], "tel:") {
+		return text
+	}
+
+	// Spaces just inside the anchor go outside the brackets, where they
+	// still separate the link from the words around it.
+	trimmed := strings.TrimSpace(text)
+	i := strings.Index(text, trimmed)
+	dest := r.destination(href, r.opts.baseURL)
+	return text[:i] + r.link(trimmed, dest, linkTitle(n.attr("title"))) + text[i+len(trimmed):]
+}
+
+// linkTitle returns the title of a link or image as written after its
+// destination, on one line and with its quotes escaped, or "" if empty.
+func linkTitle(title string) string {
+	if title = strings.Join(strings.Fields(title), " "); title == "" {
+		return ""
+	}
+	return ` "` + strings.Replace(title, `"`, `\"`, -1) + `"`
+}
+
+// destination resolves href against base and writes it in a form md can
+// hold.
+func (r *renderer) destination(href string, base *url.URL) string {
+	href = r.resolve(href, base)
+	if !strings.ContainsAny(href, " \t\n()<>") {
+		return href
+	}
+
+	if r.opts.destStyle == DestinationEncode {
+		return destinationEncoder.Replace(href)
+	}
+	return "<" + destinationEscaper.Replace(href) + ">"
+}
+
+// resolve resolves href against base unless it is kept relative.
+func (r *renderer) resolve(href string, base *url.URL) string {
+	if base == nil || href == "" {
+		return href
+	}
+
+	if r.opts.keepRelative != nil && r.opts.keepRelative(href) {
+		return href
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// headingSlugs maps the id of every heading under root to the slug generated
+// from its text, numbering repeated slugs the way GitHub does.
+func headingSlugs(root *node) map[string]string {
+	var (
+		slugs = make(map[string]string)
+		seen  = make(map[string]int)
+		walk  func(n *node)
+	)
+
+	walk = func(n *node) {
+		if n.isElement("h1", "h2", "h3", "h4", "h5", "h6") {
+			// The slug is made from the text as rendered, with its
+			// whitespace collapsed.
+			slug := slugify(strings.Join(strings.Fields(n.text()), " "))
+			if count := seen[slug]; count > 0 {
+				seen[slug]++
+				slug += "-" + strconv.Itoa(count)
+			} else {
+				seen[slug] = 1
+			}
+			if id := n.attr("id"); id != "" {
+				slugs[id] = slug
+			}
+			return
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return slugs
+}
+
+// slugify lower cases s, drops punctuation and joins words with hyphens.
+func slugify(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_':
+			b.WriteRune(c)
+		case unicode.IsSpace(c):
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// tableRows returns the <tr> elements of the table n. Rows of <tfoot> may
+// come before <tbody> in the source but are rendered last.
+func tableRows(n *node) []*node {
+	var head, body, foot []*node
+	for _, child := range n.children {
+		switch {
+		case child.isElement("thead"):
+			head = append(head, childElements(child, "tr")...)
+		case child.isElement("tbody"):
+			body = append(body, childElements(child, "tr")...)
+		case child.isElement("tfoot"):
+			foot = append(foot, childElements(child, "tr")...)
+		case child.isElement("tr"):
+			body = append(body, child)
+		}
+	}
+	return append(append(head, body...), foot...)
+}
+
+// isComplexTable reports whether the table n has cells spanning several
+// rows or columns, or holding blocks other than a single paragraph, which
+// md tables cannot show.
+func isComplexTable(n *node) bool {
+	for _, tr := range tableRows(n) {
+		for _, cell := range tr.children {
+			if !cell.isElement("td", "th") {
+				continue
+			}
+			for _, span := range []string{"rowspan", "colspan"} {
+				if v, err := strconv.Atoi(strings.TrimSpace(cell.attr(span))); err == nil && v > 1 {
+					return true
+				}
+			}
+			if p := onlyChild(cell, "p"); p == nil && hasBlock(cell.children) || p != nil && hasBlock(p.children) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isHeaderRow reports whether the row n has cells and all of them are <th>.
+func isHeaderRow(n *node) bool {
+	var th bool
+	for _, child := range n.children {
+		switch {
+		case child.isElement("th"):
+			th = true
+		case child.isElement("td"):
+			return false
+		}
+	}
+	return th
+}
+
+// columnAlign returns the alignment of column i, left, center, right or
+// empty, from the align attribute or text-align style of the first cell in
+// the column that has one.
+func columnAlign(rows []*node, i int) string {
+	for _, tr := range rows {
+		var cells []*node
+		for _, child := range tr.children {
+			if child.isElement("td", "th") {
+				cells = append(cells, child)
+			}
+		}
+		if i >= len(cells) {
+			continue
+		}
+
+		align := strings.ToLower(strings.TrimSpace(cells[i].attr("align")))
+		if align == "" {
+			align = cells[i].style("text-align")
+		}
+		switch align {
+		case "left", "center", "right":
+			return align
+		}
+	}
+	return ""
+}
+
+// separator returns the row separating the header of a table from its body,
+// with the given column alignments.
+func separator(align []string) string {
+	var b strings.Builder
+	b.WriteString("|")
+	for _, a := range align {
+		switch a {
+		case "left":
+			b.WriteString(" :-- |")
+		case "center":
+			b.WriteString(" :-: |")
+		case "right":
+			b.WriteString(" --: |")
+		default:
+			b.WriteString(" --- |")
+		}
+	}
+	return b.String()
+}
+
+// isDoubleEncoded reports whether the decoded code still looks encoded:
+// it holds character references but none of the characters a single
+// encoding would have hidden.
+func isDoubleEncoded(code string) bool {
+	return charRef.MatchString(code) && !strings.ContainsAny(code, "<>")
+}
+
+// isItalic reports whether the css font-style style is italic.
+func isItalic(style string) bool {
+	return style == "italic" || style == "oblique"
+}
+
+// isBold reports whether the css font-weight weight is bold.
+func isBold(weight string) bool {
+	if weight == "bold" || weight == "bolder" {
+		return true
+	}
+	n, err := strconv.Atoi(weight)
+	return err == nil && n >= 600
+}
+
+// preText returns the code held in the <pre> n. Highlighters that wrap every
+// line in its own <code>, or in a line anchor like <span id="LC1">, get a
+// line break between them, as does <br>.
+func preText(n *node) string {
+	var (
+		b            strings.Builder
+		codes, lines int
+		walk         func(n *node)
+	)
+
+	walk = func(n *node) {
+		switch {
+		case n.typ == textNode:
+			b.WriteString(n.data)
+			return
+		case n.isElement("br"):
+			b.WriteByte('\n')
+			return
+		case n.isElement("code") && n.parent.isElement("pre"):
+			if codes > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte('\n')
+			}
+			codes++
+		case lineAnchor.MatchString(n.attr("id")):
+			if lines > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte('\n')
+			}
+			lines++
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	return b.String()
+}
+
+// isAutolink reports whether a link to href showing text can be written as
+// the md autolink <href>.
+func isAutolink(href, text string) bool {
+	u, err := url.Parse(href)
+	return err == nil && u.Scheme != "" && href == text && !strings.ContainsAny(href, " \t\n<>")
+}
+
+// headingLevel returns the level of the heading n, or 0 if n is not one.
+func headingLevel(n *node) int {
+	if !n.isElement("h1", "h2", "h3", "h4", "h5", "h6") {
+		return 0
+	}
+	return int(n.tag[1] - '0')
+}
+
+func isBlock(n *node) bool {
+	return n.typ == elementNode && blockElements[n.tag]
+}
+
+// escape backslash-escapes the characters of the text s that md would read
+// as markup. lineStart tells whether s begins a line of its block.
+func escape(s string, lineStart bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if lineStart {
+			i += copySpaces(&b, s[i:])
+			if i == len(s) {
+				break
+			}
+			i += escapeLineStart(&b, s[i:])
+			if i == len(s) {
+				break
+			}
+			lineStart = false
+		}
+
+		switch c := s[i]; c {
+		case '*', '`', '[', ']':
+			b.WriteByte('\\')
+		case '\\':
+			if i+1 == len(s) || s[i+1] == '\n' || isPunct(s[i+1]) {
+				b.WriteByte('\\')
+			}
+		case '_':
+			// Underscores inside a word never delimit emphasis.
+			if i == 0 || i+1 == len(s) || !isAlnum(s[i-1]) || !isAlnum(s[i+1]) {
+				b.WriteByte('\\')
+			}
+		case '<':
+			if isTagStart(s[i:]) {
+				b.WriteByte('\\')
+			}
+		case '\n':
+			lineStart = true
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// copySpaces writes the spaces and tabs s starts with to b and returns how
+// many there were.
+func copySpaces(b *strings.Builder, s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	b.WriteString(s[:i])
+	return i
+}
+
+// escapeLineStart escapes the block marker, if any, s starts with, writing
+// the marker to b. It returns the length of what it wrote from s.
+func escapeLineStart(b *strings.Builder, s string) int {
+	end := func(i int) bool { return i == len(s) || s[i] == ' ' || s[i] == '\t' || s[i] == '\n' }
+
+	switch s[0] {
+	case '#':
+		i := strings.IndexFunc(s, func(r rune) bool { return r != '#' })
+		if i == -1 {
+			i = len(s)
+		}
+		if i <= 6 && end(i) {
+			b.WriteString("\\" + s[:i])
+			return i
+		}
+	case '>':
+		b.WriteString("\\>")
+		return 1
+	case '-', '+', '=':
+		if end(1) || strings.Trim(strings.SplitN(s, "\n", 2)[0], string(s[0])+" \t") == "" {
+			b.WriteString("\\" + s[:1])
+			return 1
+		}
+	}
+
+	i := 0
+	for i < len(s) && i < 9 && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(s) && (s[i] == '.' || s[i] == ')') && end(i+1) {
+		b.WriteString(s[:i] + "\\" + s[i:i+1])
+		return i + 1
+	}
+	return 0
+}
+
+func isAlnum(c byte) bool {
+	return isLetter(c) || '0' <= c && c <= '9' || c >= 0x80
+}
+
+// isPunct reports whether c is ascii punctuation, which md lets a backslash
+// escape.
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) != -1
+}
+
+// splitEmphasis pushes the <strong>, <b>, <em> and <i> elements in n that
+// wrap blocks down into those blocks, so the emphasis of a bold paragraph
+// is written around its text and does not run across the blocks.
+func splitEmphasis(n *node) {
+	for i := 0; i < len(n.children); i++ {
+		child := n.children[i]
+		splitEmphasis(child)
+		if !child.isElement("strong", "b", "em", "i") || !hasBlock(child.children) {
+			continue
+		}
+
+		nodes := distribute(child, child.children)
+		for _, c := range nodes {
+			c.parent = n
+		}
+		n.children = append(n.children[:i], append(nodes, n.children[i+1:]...)...)
+		i += len(nodes) - 1
+	}
+}
+
+// mergeEmphasis joins the emphasis elements in n that directly follow one
+// of the same kind into it, as <em>a</em><em>b</em> would otherwise be
+// written *a**b*, which md reads as one run of markers.
+func mergeEmphasis(n *node) {
+	var prev *node
+	children := n.children[:0]
+	for _, child := range n.children {
+		mergeEmphasis(child)
+		if prev != nil && sameEmphasis(prev, child) {
+			for _, c := range child.children {
+				prev.appendChild(c)
+			}
+			continue
+		}
+		children = append(children, child)
+		prev = child
+	}
+	n.children = children
+}
+
+// sameEmphasis reports whether a and b are both bold or both italic
+// elements.
+func sameEmphasis(a, b *node) bool {
+	return a.isElement("strong", "b") && b.isElement("strong", "b") || a.isElement("em", "i") && b.isElement("em", "i")
+}
+
+// distribute wraps every run of inline nodes in a copy of the element e,
+// going into blocks, and returns the result.
+func distribute(e *node, nodes []*node) []*node {
+	var (
+		out []*node
+		run []*node
+	)
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if strings.TrimSpace((&node{children: run}).text()) == "" && find(&node{children: run}, "img") == nil {
+			out = append(out, run...)
+		} else {
+			wrapper := &node{typ: elementNode, tag: e.tag, attrs: e.attrs}
+			for _, c := range run {
+				wrapper.appendChild(c)
+			}
+			out = append(out, wrapper)
+		}
+		run = nil
+	}
+
+	for _, n := range nodes {
+		if !isBlock(n) {
+			run = append(run, n)
+			continue
+		}
+		flush()
+		if !n.isElement("pre") {
+			n.children = distribute(e, n.children)
+			for _, c := range n.children {
+				c.parent = n
+			}
+		}
+		out = append(out, n)
+	}
+	flush()
+
+	return out
+}
+
+// letters returns num, from 1, as A, B, ..., Z, AA, AB and so on.
+func letters(num int) string {
+	var b []byte
+	for ; num > 0; num = (num - 1) / 26 {
+		b = append([]byte{byte('A' + (num-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// roman returns num, from 1, as a roman numeral.
+func roman(num int) string {
+	var (
+		b       strings.Builder
+		values  = []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+		numeral = []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	)
+	for i, v := range values {
+		for ; num >= v; num -= v {
+			b.WriteString(numeral[i])
+		}
+	}
+	return b.String()
+}
+
+// displayWidth returns the number of terminal columns s takes up: two for
+// east asian wide and fullwidth characters, none for combining marks.
+func displayWidth(s string) int {
+	width := 0
+	for _, c := range s {
+		switch {
+		case unicode.Is(unicode.Mn, c) || unicode.Is(unicode.Me, c) || c == '\u200b':
+		case isWide(c):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+func isWide(c rune) bool {
+	return 0x1100 <= c && c <= 0x115f ||
+		0x2e80 <= c && c <= 0xa4cf && c != 0x303f ||
+		0xac00 <= c && c <= 0xd7a3 ||
+		0xf900 <= c && c <= 0xfaff ||
+		0xfe30 <= c && c <= 0xfe4f ||
+		0xff00 <= c && c <= 0xff60 ||
+		0xffe0 <= c && c <= 0xffe6 ||
+		0x1f300 <= c && c <= 0x1f64f ||
+		0x1f900 <= c && c <= 0x1f9ff ||
+		0x20000 <= c && c <= 0x3fffd
+}
+
+// longestRun returns the length of the longest run of c in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		if run++; run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// wrap surrounds s with mark, keeping leading and trailing spaces outside.
+func wrap(s, mark string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+
+	i := strings.Index(s, text)
+	return s[:i] + mark + text + mark + s[i+len(text):]
+}
+
+// indent prefixes the first line of s with marker and the following
+// non-empty lines with spaces of the same width.
+func indent(s, marker string) string {
+	pad := strings.Repeat(" ", len(marker))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = marker + line
+		case line != "":
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
 }
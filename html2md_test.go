@@ -3,18 +3,1676 @@ package html2md
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 )
 
+func convert(s string, opts ...Option) string {
+	md, err := NewConverter(opts...).Convert(s)
+	if e, ok := err.(*Error); ok && e.panic != nil {
+		panic(e.panic)
+	}
+	return md
+}
+
+func TestAltCaption(t *testing.T) {
+	var (
+		long = "Heap allocations per second before and after switching to AppendFormat"
+		s    = `<figure><img src="a.png" alt="` + long + `"></figure><p><img src="b.png" alt="logo"></p>`
+	)
+
+	want := "![" + long + "](a.png)  \n*" + long + "*\n\n![logo](b.png)\n"
+	if md := convert(s, WithAltCaption(40)); md != want {
+		t.Errorf("with caption: got %q, want %q", md, want)
+	}
+
+	const starred = `<img src="c.png" alt="Allocations *per second* before_and_after">`
+	want = "![Allocations *per second* before_and_after](c.png)  \n*Allocations \\*per second\\* before_and_after*\n"
+	if md := convert(starred, WithAltCaption(10)); md != want {
+		t.Errorf("escaped caption: got %q, want %q", md, want)
+	}
+
+	want = "![" + long + "](a.png)\n\n![logo](b.png)\n"
+	if md := convert(s); md != want {
+		t.Errorf("without caption: got %q, want %q", md, want)
+	}
+
+	linked := `<p><a href="/heap"><img src="a.png" alt="` + long + `"></a></p>` +
+		`<h2><img src="a.png" alt="` + long + `"> Heap</h2>`
+	want = "[![" + long + "](a.png)](/heap)\n\n## ![" + long + "](a.png) Heap\n"
+	if md := convert(linked, WithAltCaption(40)); md != want {
+		t.Errorf("in link and heading: got %q, want %q", md, want)
+	}
+}
+
+func TestMaxAltLength(t *testing.T) {
+	const (
+		long = "Heap allocations per second before and after switching to AppendFormat"
+		s    = `<p><img src="a.png" alt="` + long + `"> <img src="b.png" alt="logo"></p>`
+	)
+
+	want := "![Heap allocations per…](a.png) ![logo](b.png)\n"
+	if md := convert(s, WithMaxAltLength(21)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	want = "![Heap allocations per…](a.png)  \n*" + long + "*  \n![logo](b.png)\n"
+	if md := convert(s, WithMaxAltLength(21), WithAltCaption(40)); md != want {
+		t.Errorf("with caption: got %q, want %q", md, want)
+	}
+}
+
+func TestImageAltTitle(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<img src="heap.png" alt="Heap" title="Heap &quot;profile&quot;">`, "![Heap](heap.png \"Heap \\\"profile\\\"\")\n"},
+		{`<img src="heap.png">`, "![](heap.png)\n"},
+		{`<img src="heap.png" alt="[1] heap]">`, "![\\[1\\] heap\\]](heap.png)\n"},
+		{`<a href="/heap"><img src="heap.png" alt="Heap" title="Profile"></a>`, "[![Heap](heap.png \"Profile\")](/heap)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestDecorativeImages(t *testing.T) {
+	const s = `<p><img src="divider.png" alt=""> Pools <a href="/"><img src="dot.png" alt=""></a><img src="heap.png"></p>`
+
+	if md, want := convert(s), "![](divider.png) Pools [![](dot.png)](/)![](heap.png)\n"; md != want {
+		t.Errorf("kept: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithDropDecorativeImages(true)), "Pools ![](heap.png)\n"; md != want {
+		t.Errorf("dropped: got %q, want %q", md, want)
+	}
+}
+
+func TestListParagraphItems(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<ul><li><p>a</p></li><li><p>b</p></li></ul>", "* a\n* b\n"},
+		{"<ol>\n<li>\n<p>a</p>\n</li>\n<li><p>b</p></li>\n</ol>", "1. a\n2. b\n"},
+		{"<ul><li><p>a</p><ul><li><p>b</p></li></ul></li><li><p>c</p></li></ul>", "* a\n  * b\n* c\n"},
+		{"<ul><li><p>a</p><p>b</p></li><li><p>c</p></li></ul>", "* a\n\n  b\n\n* c\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestListPaddedItems(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<ul><li>\n  <p></p>\n  <p>a</p>\n  <p> </p>\n</li><li>  b  </li></ul>", "* a\n* b\n"},
+		{"<ol><li>a</li><li>\n</li><li><p></p></li><li>b</li></ol>", "1. a\n2. b\n"},
+		{"<ul><li><p></p><p>a</p><p>b</p><p></p></li></ul>", "* a\n\n  b\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestNestedLists(t *testing.T) {
+	const s = "<ul><li>heap<ol><li>escape<ul><li>pointers</li></ul></li><li>size</li></ol></li>" +
+		"<li><p>stack</p><ul><li>frames</li></ul></li></ul>"
+
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{0, "* heap\n  1. escape\n     * pointers\n  2. size\n* stack\n  * frames\n"},
+		{2, "* heap\n  1. escape\n     * pointers\n  2. size\n* stack\n  * frames\n"},
+		{4, "*   heap\n    1.  escape\n        *   pointers\n    2.  size\n*   stack\n    *   frames\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithListIndent(test.width)); md != test.want {
+			t.Errorf("width %d: got %q, want %q", test.width, md, test.want)
+		}
+	}
+}
+
+func TestListSpacing(t *testing.T) {
+	tests := []struct {
+		html    string
+		spacing ListSpacing
+		want    string
+	}{
+		{"<ul><li>a</li><li>b</li></ul>", ListAuto, "* a\n* b\n"},
+		{"<ul><li>a</li><li>b</li></ul>", ListTight, "* a\n* b\n"},
+		{"<ul><li>a</li><li>b</li></ul>", ListLoose, "* a\n\n* b\n"},
+		{"<ul><li><p>a</p><p>c</p></li><li>b</li></ul>", ListAuto, "* a\n\n  c\n\n* b\n"},
+		{"<ul><li><p>a</p><p>c</p></li><li>b</li></ul>", ListTight, "* a\n\n  c\n* b\n"},
+		{"<ul><li><p>a</p><p>c</p></li><li>b</li></ul>", ListLoose, "* a\n\n  c\n\n* b\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithListSpacing(test.spacing)); md != test.want {
+			t.Errorf("%q, spacing %d: got %q, want %q", test.html, test.spacing, md, test.want)
+		}
+	}
+}
+
+func TestKeepRelative(t *testing.T) {
+	var (
+		s    = `<p><a href="guide.md">guide</a> <a href="/about">about</a> <img src="img/a.png" alt="a"></p>`
+		want = "[guide](guide.md) [about](https://example.com/about) ![a](https://example.com/docs/img/a.png)\n"
+	)
+
+	md := convert(s, WithBaseURL("https://example.com/docs/"), WithKeepRelative(func(href string) bool {
+		return strings.HasSuffix(href, ".md")
+	}))
+	if md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestDiffStyle(t *testing.T) {
+	const s = "<p>Call <del>Format</del> <ins>AppendFormat</ins> instead.</p>"
+
+	tests := []struct {
+		style DiffStyle
+		want  string
+	}{
+		{DiffText, "Call ~~Format~~ AppendFormat instead.\n"},
+		{DiffMarkers, "Call ~~Format~~ **AppendFormat** instead.\n"},
+		{DiffHTML, "Call <del>Format</del> <ins>AppendFormat</ins> instead.\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithDiffStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestStrikethrough(t *testing.T) {
+	const s = "<p><del>Sprintf</del> <s>Format</s> <strike><em>Fprintf</em></strike></p>"
+
+	if md, want := convert(s), "~~Sprintf~~ ~~Format~~ ~~*Fprintf*~~\n"; md != want {
+		t.Errorf("gfm: got %q, want %q", md, want)
+	}
+
+	want := "<del>Sprintf</del> <s>Format</s> <strike>*Fprintf*</strike>\n"
+	if md := convert(s, WithStrikethrough(false)); md != want {
+		t.Errorf("html: got %q, want %q", md, want)
+	}
+}
+
+func TestHeadingSlugs(t *testing.T) {
+	const s = `<h2 id="sec-1">Some Pointers</h2><h2 id="tools-of-the-trade">Tools of the Trade</h2>` +
+		"<h2 id=\"t\">Tools of\n   the Trade</h2>" +
+		`<p><a href="#sec-1">pointers</a>, <a href="#tools-of-the-trade">tools</a>, <a href="#t">again</a>, ` +
+		`<a href="#missing">missing</a></p>`
+
+	want := "## Some Pointers\n\n## Tools of the Trade\n\n## Tools of the Trade\n\n" +
+		"[pointers](#some-pointers), [tools](#tools-of-the-trade), [again](#tools-of-the-trade-1), [missing](#missing)\n"
+	if md := convert(s, WithHeadingSlugs(true)); md != want {
+		t.Errorf("with slugs: got %q, want %q", md, want)
+	}
+
+	want = "## Some Pointers\n\n## Tools of the Trade\n\n## Tools of the Trade\n\n" +
+		"[pointers](#sec-1), [tools](#tools-of-the-trade), [again](#t), [missing](#missing)\n"
+	if md := convert(s); md != want {
+		t.Errorf("without slugs: got %q, want %q", md, want)
+	}
+}
+
+func TestTableFootAfterBody(t *testing.T) {
+	const s = "<table><thead><tr><th>Item</th><th>Allocs</th></tr></thead>" +
+		"<tfoot><tr><td>Total</td><td>3</td></tr></tfoot>" +
+		"<tbody><tr><td>Format</td><td>2</td></tr><tr><td>AppendFormat</td><td>1</td></tr></tbody></table>"
+
+	want := "| Item | Allocs |\n| --- | --- |\n| Format | 2 |\n| AppendFormat | 1 |\n| Total | 3 |\n"
+	if md := convert(s); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestTableHeaderOnly(t *testing.T) {
+	for _, s := range []string{
+		"<table><thead><tr><th>Item</th><th>Allocs</th></tr></thead></table>",
+		"<table><thead><tr><th>Item</th><th>Allocs</th></tr></thead><tbody>\n</tbody></table>",
+	} {
+		if md, want := convert(s), "| Item | Allocs |\n| --- | --- |\n"; md != want {
+			t.Errorf("%q: got %q, want %q", s, md, want)
+		}
+	}
+}
+
+func TestTableCaption(t *testing.T) {
+	for _, s := range []string{
+		`<table><caption align="bottom" style="caption-side: bottom">Allocs per <code>op</code></caption>` +
+			"<tr><th>Func</th></tr><tr><td>Format</td></tr></table>",
+		"<table><tr><th>Func</th></tr><tr><td>Format</td></tr><caption>\n  Allocs per <code>op</code>\n</caption></table>",
+	} {
+		if md, want := convert(s), "*Allocs per `op`*\n\n| Func |\n| --- |\n| Format |\n"; md != want {
+			t.Errorf("%q: got %q, want %q", s, md, want)
+		}
+	}
+}
+
+func TestTableScripts(t *testing.T) {
+	const s = "<table><tr><th>Size</th></tr><tr><td>10<sup>\n6<br></sup> x<sub>i</sub></td></tr></table>"
+
+	tests := []struct {
+		style ScriptStyle
+		want  string
+	}{
+		{ScriptText, "| Size |\n| --- |\n| 106 xi |\n"},
+		{ScriptHTML, "| Size |\n| --- |\n| 10<sup>6</sup> x<sub>i</sub> |\n"},
+		{ScriptCaret, "| Size |\n| --- |\n| 10^6^ x~i~ |\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithScriptStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestTableAlignment(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			`<table><thead><tr><th align="left">Func</th><th style="text-align: center">Note</th><th align="RIGHT">ns/op</th><th>B/op</th></tr></thead>` +
+				`<tbody><tr><td><a href="/fmt">Sprintf</a></td><td><strong>slow</strong></td><td>120</td><td>16</td></tr></tbody></table>`,
+			"| Func | Note | ns/op | B/op |\n| :-- | :-: | --: | --- |\n| [Sprintf](/fmt) | **slow** | 120 | 16 |\n",
+		},
+		{
+			`<table><tr><td>a | b</td><td align="right"><code>x||y</code></td></tr><tr><td>c</td><td>d</td></tr></table>`,
+			"|  |  |\n| --- | --: |\n| a \\| b | `x\\|\\|y` |\n| c | d |\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestLayoutTable(t *testing.T) {
+	const s = `<table role="presentation"><tr><td><h1>Weekly Go</h1></td></tr>` +
+		`<tr><td><p>Pools <b>cut</b> allocations.</p><p>Read more.</p></td><td>Unsubscribe</td></tr></table>`
+
+	want := "# Weekly Go\n\nPools **cut** allocations.\n\nRead more.\n\nUnsubscribe\n"
+	if md := convert(s); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestTableInBlockquote(t *testing.T) {
+	const s = `<blockquote><p>Results:</p><table><tr><th>Func</th><th align="right">ns/op</th></tr>` +
+		`<tr><td>Sprintf</td><td>120</td></tr></table></blockquote>`
+
+	want := "> Results:\n>\n> | Func | ns/op |\n> | --- | --: |\n> | Sprintf | 120 |\n"
+	md := convert(s)
+	if md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	table := convert(strings.Replace(strings.Replace(s, "<blockquote><p>Results:</p>", "", 1), "</blockquote>", "", 1))
+	if unquoted := strings.Replace(strings.SplitN(md, "\n", 3)[2], "> ", "", -1); unquoted != table {
+		t.Errorf("unquoted: got %q, want %q", unquoted, table)
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, s := range []string{"", "   ", "<html></html>", "<!DOCTYPE html><html><body>\n</body></html>"} {
+		var called bool
+		md := ParseHTMLtoMD(s, func(err interface{}) {
+			called = true
+		})
+		if md != "" || called {
+			t.Errorf("%q: got %q, callback called %v", s, md, called)
+		}
+	}
+}
+
+func TestParagraphPrefix(t *testing.T) {
+	const s = "<p>first<br>line</p><pre><code>x := 42</code></pre><ul><li>item</li></ul>second"
+
+	want := "    first  \n    line\n\n```\nx := 42\n```\n\n* item\n\n    second\n"
+	if md := convert(s, WithParagraphPrefix("    ")); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestPreMultipleCode(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			`<pre data-language="go"><code>func main() {</code><code>    x := 42</code><code>}</code></pre>`,
+			"```go\nfunc main() {\n    x := 42\n}\n```\n",
+		},
+		{
+			"<pre><code><span>a</span> <span>:=</span> 1</code>\n<code>b := 2</code></pre>",
+			"```\na := 1\nb := 2\n```\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestPreLineAnchors(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			`<pre><code><span id="LC1" class="line">a := 1</span>` + "\n" + `<span id="LC2" class="line">b := a</span></code></pre>`,
+			"```\na := 1\nb := a\n```\n",
+		},
+		{
+			`<pre lang="go"><span id="LC1"><span class="k">var</span> a int</span><span id="LC2">a++</span><span id="LC3"></span></pre>`,
+			"```\nvar a int\na++\n```\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestPreLeadingWhitespace(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<pre><code>    if x {\n        y()\n    }</code></pre>", "```\n    if x {\n        y()\n    }\n```\n"},
+		{"<ul><li><pre><code>    a\nb</code></pre></li></ul>", "* ```\n      a\n  b\n  ```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestPreFenceLines(t *testing.T) {
+	for _, s := range []string{
+		"<pre><code>b.Reset()</code></pre>",
+		"<pre>b.Reset()\n</pre>",
+		"<pre><code>\nb.Reset()\n</code></pre>",
+	} {
+		md := convert(s)
+		if lines := strings.Split(strings.TrimSuffix(md, "\n"), "\n"); len(lines) != 3 ||
+			lines[0] != "```" || lines[1] != "b.Reset()" || lines[2] != "```" {
+			t.Errorf("%q: got %q, want three lines", s, md)
+		}
+	}
+}
+
+func TestReversedList(t *testing.T) {
+	const s = "<ol reversed><li>bronze</li><li>silver</li><li>gold</li></ol>"
+
+	if md, want := convert(s), "1. bronze\n2. silver\n3. gold\n"; md != want {
+		t.Errorf("ascending: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithReversedLists(true)), "3. bronze\n2. silver\n1. gold\n"; md != want {
+		t.Errorf("descending: got %q, want %q", md, want)
+	}
+	const empty = "<ol reversed><li>a</li><li> </li><li>b</li></ol>"
+	if md, want := convert(empty, WithReversedLists(true)), "2. a\n1. b\n"; md != want {
+		t.Errorf("empty item: got %q, want %q", md, want)
+	}
+}
+
+func TestOrderedListDelimiter(t *testing.T) {
+	const s = "<ol><li>measure<ol><li>profile</li><li>benchmark</li></ol></li><li>optimize</li></ol>"
+
+	want := "1) measure\n   1) profile\n   2) benchmark\n2) optimize\n"
+	if md := convert(s, WithOrderedListDelimiter(')')); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	want = "1. measure\n   1. profile\n   2. benchmark\n2. optimize\n"
+	if md := convert(s, WithOrderedListDelimiter('-')); md != want {
+		t.Errorf("invalid delimiter: got %q, want %q", md, want)
+	}
+}
+
+func TestOrderedListStart(t *testing.T) {
+	tests := []struct {
+		html string
+		opts []Option
+		want string
+	}{
+		{`<ol start="5"><li>a<ol><li>x</li><li>y</li></ol></li><li>b</li></ol>`, nil, "5. a\n   1. x\n   2. y\n6. b\n"},
+		{`<ol start="ten"><li>a</li><li>b</li></ol>`, nil, "1. a\n2. b\n"},
+		{`<ol start="-3"><li>a</li></ol>`, nil, "1. a\n"},
+		{`<ol start="0"><li>a</li></ol>`, nil, "0. a\n"},
+		{`<ol reversed start="10"><li>a</li><li>b</li></ol>`, []Option{WithReversedLists(true)}, "10. a\n9. b\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, test.opts...); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestListTypeMarkers(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<ol type="a"><li>x</li><li>y</li></ol>`, "a. x\nb. y\n"},
+		{`<ol type="A" start="26"><li>x</li><li>y</li><li>z</li></ol>`, "Z. x\nAA. y\nAB. z\n"},
+		{`<ol type="i" start="3"><li>x<ol type="I" start="9"><li>y</li><li>z</li></ol></li><li>w</li></ol>`, "iii. x\n     IX. y\n     X. z\niv. w\n"},
+		{`<ol type="1"><li>x</li></ol>`, "1. x\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithListTypeMarkers(true)); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+
+	if md, want := convert(`<ol type="a"><li>x</li></ol>`), "1. x\n"; md != want {
+		t.Errorf("disabled: got %q, want %q", md, want)
+	}
+
+	for num, want := range map[int]string{1: "A", 26: "Z", 27: "AA", 52: "AZ", 703: "AAA"} {
+		if got := letters(num); got != want {
+			t.Errorf("letters(%d): got %q, want %q", num, got, want)
+		}
+	}
+	for num, want := range map[int]string{4: "IV", 9: "IX", 14: "XIV", 1994: "MCMXCIV"} {
+		if got := roman(num); got != want {
+			t.Errorf("roman(%d): got %q, want %q", num, got, want)
+		}
+	}
+}
+
+func TestDestinationStyle(t *testing.T) {
+	const s = `<p><a href="my notes.md">notes</a> <a href="https://en.wikipedia.org/wiki/Go_(programming_language)">Go</a></p>`
+
+	tests := []struct {
+		style DestinationStyle
+		want  string
+	}{
+		{DestinationAngle, "[notes](<my notes.md>) [Go](<https://en.wikipedia.org/wiki/Go_(programming_language)>)\n"},
+		{DestinationEncode, "[notes](my%20notes.md) [Go](https://en.wikipedia.org/wiki/Go_%28programming_language%29)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithDestinationStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestFigureWithoutImage(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			`<figure><pre data-language="go"><code>x := 42</code></pre><figcaption>Listing 1: escaping</figcaption></figure>`,
+			"```go\nx := 42\n```\n\n*Listing 1: escaping*\n",
+		},
+		{
+			"<figure><figcaption>Rob Pike</figcaption><blockquote><p>Clear is better than clever.</p></blockquote></figure>",
+			"> Clear is better than clever.\n\n*Rob Pike*\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestCodeLanguage(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<pre data-language="go"><code>x</code></pre>`, "```go\nx\n```\n"},
+		{`<pre><code data-lang="python">x</code></pre>`, "```python\nx\n```\n"},
+		{`<pre data-syntax="rust"><code>x</code></pre>`, "```rust\nx\n```\n"},
+		{`<pre data-lang="c"><code data-language="go">x</code></pre>`, "```c\nx\n```\n"},
+		{`<pre><code>x</code></pre>`, "```\nx\n```\n"},
+		{`<pre><code class="hljs language-go">x</code></pre>`, "```go\nx\n```\n"},
+		{`<pre class="lang-Python"><code>x</code></pre>`, "```python\nx\n```\n"},
+		{`<pre data-language="Go"><code class="language-rust">x</code></pre>`, "```go\nx\n```\n"},
+		{`<pre class="lang-c"><code class="language-cpp">x</code></pre>`, "```cpp\nx\n```\n"},
+		{`<pre><code class="language-">x</code></pre>`, "```\nx\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestCaptionStyle(t *testing.T) {
+	const (
+		image = `<figure><img src="a.png" alt="A"><figcaption>The <b>cap</b></figcaption></figure>`
+		table = `<figure><table><tr><th>a</th></tr><tr><td>1</td></tr></table><figcaption>T</figcaption></figure>`
+	)
+
+	tests := []struct {
+		html  string
+		style CaptionStyle
+		want  string
+	}{
+		{image, CaptionParagraph, "![A](a.png)\n\n*The **cap***\n"},
+		{image, CaptionLine, "![A](a.png)  \n*The **cap***\n"},
+		{`<figure><img src="a.png" alt="A"></figure>`, CaptionLine, "![A](a.png)\n"},
+		{table, CaptionParagraph, "| a |\n| --- |\n| 1 |\n\n*T*\n"},
+		{table, CaptionLine, "| a |\n| --- |\n| 1 |\n\n*T*\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithCaptionStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+
+	if md, want := convert(image), "![A](a.png)  \n*The **cap***\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
+func TestMergeBlockquotes(t *testing.T) {
+	const s = "<blockquote><p>a</p></blockquote>\n<blockquote><p>b</p></blockquote><p>c</p><blockquote><p>d</p></blockquote>"
+
+	if md, want := convert(s), "> a\n\n> b\n\nc\n\n> d\n"; md != want {
+		t.Errorf("separate: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithMergeBlockquotes(true)), "> a\n>\n> b\n\nc\n\n> d\n"; md != want {
+		t.Errorf("merged: got %q, want %q", md, want)
+	}
+}
+
+func TestLinkedImage(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<a href="x"> <img src="y" alt="z"> </a>`, "[![z](y)](x)\n"},
+		{"<p>See <a href=\"x\">\n  <img src=\"y\" alt=\"z\">\n</a> here</p>", "See [![z](y)](x) here\n"},
+		{`<a href="x"><img src="y" alt="z"> caption</a>`, "[![z](y) caption](x)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestTrimCodeBlocks(t *testing.T) {
+	tests := []struct {
+		html      string
+		trim, raw string
+	}{
+		{"<pre><code>\nx := 42\n</code></pre>", "```\nx := 42\n```\n", "```\n\nx := 42\n```\n"},
+		{"<pre>\n\nx := 42</pre>", "```\n\nx := 42\n```\n", "```\n\n\nx := 42\n```\n"},
+		{"<pre>x := 42\n\n</pre>", "```\nx := 42\n\n```\n", "```\nx := 42\n\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.trim {
+			t.Errorf("%q trimmed: got %q, want %q", test.html, md, test.trim)
+		}
+		if md := convert(test.html, WithTrimCodeBlocks(false)); md != test.raw {
+			t.Errorf("%q raw: got %q, want %q", test.html, md, test.raw)
+		}
+	}
+}
+
+func TestDropFragmentLinks(t *testing.T) {
+	const s = `<p><a href="#top">Back to top</a> or read <a href="https://blog.golang.org/">the blog</a>.</p>`
+
+	want := "Back to top or read [the blog](https://blog.golang.org/).\n"
+	if md := convert(s, WithDropFragmentLinks(true)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestAstralReferences(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p>&#128512; &#x1F600;</p>", "😀 😀\n"},
+		{"<p>&#xD83D;&#xDE00; &#55357;&#56832;</p>", "😀 😀\n"},
+		{"<p>&#xD83D; alone</p>", "\uFFFD alone\n"},
+		{`<img src="a.png" alt="&#x1F680;">`, "![🚀](a.png)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestMathClass(t *testing.T) {
+	const s = `<p>Energy <span class="math inline" data-latex="E = mc^2"><span>E=mc²</span></span> and <span class="math">x_1</span>.</p>`
+
+	if md, want := convert(s, WithMathClass("math")), "Energy $E = mc^2$ and $x_1$.\n"; md != want {
+		t.Errorf("with class: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s), "Energy E=mc² and x_1.\n"; md != want {
+		t.Errorf("without class: got %q, want %q", md, want)
+	}
+}
+
+func TestStripZeroWidth(t *testing.T) {
+	const s = "<p>\ufeffzero\u200bwidth <code>a\u200bb</code></p>"
+
+	if md, want := convert(s), "zerowidth `a\u200bb`\n"; md != want {
+		t.Errorf("stripped: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithStripZeroWidth(false)), "\ufeffzero\u200bwidth `a\u200bb`\n"; md != want {
+		t.Errorf("kept: got %q, want %q", md, want)
+	}
+}
+
+func TestOrderedListContinuationIndent(t *testing.T) {
+	s := "<ol>" + strings.Repeat("<li><p>a</p><p>b</p></li>", 9) + "<li><p>a</p><p>b</p><ul><li>c</li></ul></li></ol>"
+
+	want := "9. a\n\n   b\n\n10. a\n\n    b\n    * c\n"
+	if md := convert(s); !strings.HasSuffix(md, want) {
+		t.Errorf("got %q, want suffix %q", md, want)
+	}
+}
+
+func TestStyleRecognition(t *testing.T) {
+	const s = `<p><span style="font-weight:bold">bold</span>, <span style="font-style: italic">italic</span>, ` +
+		`<span style="FONT-WEIGHT: 700; font-style: italic">both</span> and <span style="color:red">plain</span></p>`
+
+	want := "**bold**, *italic*, ***both*** and plain\n"
+	if md := convert(s, WithStyleRecognition(true)); md != want {
+		t.Errorf("recognized: got %q, want %q", md, want)
+	}
+
+	want = "bold, italic, both and plain\n"
+	if md := convert(s); md != want {
+		t.Errorf("ignored: got %q, want %q", md, want)
+	}
+}
+
+func TestNestedStyleEmphasis(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<em><span style="font-style:italic">x</span></em>`, "*x*\n"},
+		{`<strong><span style="font-weight:bold">x</span></strong>`, "**x**\n"},
+		{`<span style="font-weight:bold"><b>x</b></span>`, "**x**\n"},
+		{`<span style="font-style:italic">a <span style="font-style:italic">b</span></span>`, "*a b*\n"},
+		{`<em><span style="font-weight:bold">x</span></em>`, "***x***\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithStyleRecognition(true)); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestStyleStrikethrough(t *testing.T) {
+	const s = `<p>Use <span style="text-decoration:line-through">Sprintf</span> ` +
+		`<span style="text-decoration: underline line-through red">Format</span> AppendFormat.</p>`
+
+	want := "Use ~~Sprintf~~ ~~Format~~ AppendFormat.\n"
+	if md := convert(s, WithStyleRecognition(true)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestNestedEmphasis(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<strong><strong>x</strong></strong>", "**x**\n"},
+		{"<em><em>x</em></em>", "*x*\n"},
+		{"<b>a <strong>b</strong> c</b>", "**a b c**\n"},
+		{"<i>a <em>b</em></i>", "*a b*\n"},
+		{"<strong><em><strong>x</strong></em></strong>", "***x***\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestAdjacentEmphasis(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<em>a</em><em>b</em>", "*ab*\n"},
+		{"<i>a</i><em>b</em> <em>c</em>", "*ab* *c*\n"},
+		{"<strong>x</strong><b>y</b>", "**xy**\n"},
+		{"<em>a </em><em><strong>b</strong></em>", "*a **b***\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestDoubleEncodedCode(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<pre><code>if max &amp;lt; bufSize &amp;amp;&amp;amp; ok {</code></pre>", "```\nif max < bufSize && ok {\n```\n"},
+		{"<pre><code>&lt;p&gt;&amp;amp;&lt;/p&gt;</code></pre>", "```\n<p>&amp;</p>\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithDoubleEncodedCode(true)); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+
+	const s = "<pre><code>a &amp;lt; b</code></pre>"
+	if md, want := convert(s), "```\na &lt; b\n```\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
+func TestBlankLineAfterHeadings(t *testing.T) {
+	const s = "<h1>Title</h1>intro<h2>Section</h2><ul><li>a</li></ul><div><h3>Sub</h3><p>text</p></div>"
+
+	want := "# Title\n\nintro\n\n## Section\n\n* a\n\n### Sub\n\ntext\n"
+	if md := convert(s); md != want {
+		t.Errorf("blank: got %q, want %q", md, want)
+	}
+
+	want = "# Title\nintro\n\n## Section\n* a\n\n### Sub\ntext\n"
+	if md := convert(s, WithBlankLineAfterHeadings(false)); md != want {
+		t.Errorf("compact: got %q, want %q", md, want)
+	}
+}
+
+func TestImageBaseURL(t *testing.T) {
+	const s = `<p><a href="posts/go">post</a> <img src="asset_ognsdc07.png" alt="profile"></p>`
+
+	want := "[post](https://segment.com/blog/posts/go) ![profile](https://assets.contents.io/asset_ognsdc07.png)\n"
+	if md := convert(s, WithBaseURL("https://segment.com/blog/"), WithImageBaseURL("https://assets.contents.io/")); md != want {
+		t.Errorf("image base: got %q, want %q", md, want)
+	}
+
+	want = "[post](https://segment.com/blog/posts/go) ![profile](https://segment.com/blog/asset_ognsdc07.png)\n"
+	if md := convert(s, WithBaseURL("https://segment.com/blog/")); md != want {
+		t.Errorf("fallback: got %q, want %q", md, want)
+	}
+}
+
+func TestEmptyCellPlaceholder(t *testing.T) {
+	const s = "<table><tr><th>Field</th><th>Pointer</th></tr><tr><td>id</td><td></td></tr><tr><td>loc</td></tr></table>"
+
+	want := "| Field | Pointer |\n| --- | --- |\n| id | — |\n| loc | — |\n"
+	if md := convert(s, WithEmptyCellPlaceholder("—")); md != want {
+		t.Errorf("placeholder: got %q, want %q", md, want)
+	}
+
+	want = "| Field | Pointer |\n| --- | --- |\n| id |  |\n| loc |  |\n"
+	if md := convert(s); md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
+func TestDownloadStyle(t *testing.T) {
+	const s = `<p>Get <a href="/report.pdf" download>the report</a>.</p>`
+
+	tests := []struct {
+		style DownloadStyle
+		want  string
+	}{
+		{DownloadLink, "Get [the report](/report.pdf).\n"},
+		{DownloadAnnotate, "Get [the report (download)](/report.pdf).\n"},
+		{DownloadHTML, "Get <a href=\"/report.pdf\" download>the report</a>.\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithDownloadStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestConsecutiveHeadings(t *testing.T) {
+	const s = "<h2>Some Pointers</h2><h2>Digging for Pointer Gold</h2><h4>Notes</h4><p>text</p><h2>Takeaways</h2>"
+
+	want := "## Some Pointers\n\n## Digging for Pointer Gold\n\n#### Notes\n\ntext\n\n## Takeaways\n"
+	if md := convert(s); md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want = "## Some Pointers\n\n### Digging for Pointer Gold\n\n#### Notes\n\ntext\n\n## Takeaways\n"
+	if md := convert(s, WithDemoteConsecutiveHeadings(true)); md != want {
+		t.Errorf("demoted: got %q, want %q", md, want)
+	}
+}
+
+func TestCodeAutolinks(t *testing.T) {
+	const s = `<p>See <a href="https://godoc.org/github.com/segmentio/ksuid"><code>https://godoc.org/github.com/segmentio/ksuid</code></a> ` +
+		`and <a href="https://godoc.org/github.com/segmentio/ksuid#KSUID"><code>KSUID</code></a>.</p>`
+
+	want := "See [`https://godoc.org/github.com/segmentio/ksuid`](https://godoc.org/github.com/segmentio/ksuid) " +
+		"and [`KSUID`](https://godoc.org/github.com/segmentio/ksuid#KSUID).\n"
+	if md := convert(s); md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want = "See <https://godoc.org/github.com/segmentio/ksuid> and [`KSUID`](https://godoc.org/github.com/segmentio/ksuid#KSUID).\n"
+	if md := convert(s, WithCodeAutolinks(true)); md != want {
+		t.Errorf("autolink: got %q, want %q", md, want)
+	}
+}
+
+func TestBlockquoteCite(t *testing.T) {
+	const s = `<blockquote cite="https://go-proverbs.github.io/"><p>Clear is better than clever.</p></blockquote>`
+
+	if md, want := convert(s), "> Clear is better than clever.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want := "> Clear is better than clever.\n>\n> — <https://go-proverbs.github.io/>\n"
+	if md := convert(s, WithBlockquoteCite(true)); md != want {
+		t.Errorf("cite: got %q, want %q", md, want)
+	}
+}
+
+func TestNestedContainers(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			"<ul><li><p>Run:</p><blockquote><p>Note</p><pre data-language=\"sh\"><code>go build\n\ngo vet</code></pre></blockquote></li><li>next</li></ul>",
+			"* Run:\n\n  > Note\n  >\n  > ```sh\n  > go build\n  >\n  > go vet\n  > ```\n\n* next\n",
+		},
+		{
+			"<ol><li><blockquote><p>a<br>b</p></blockquote></li></ol>",
+			"1. > a  \n   > b\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestHighlightStyle(t *testing.T) {
+	const s = "<p>Stack allocation is <mark>cheap</mark>.</p>"
+
+	tests := []struct {
+		style HighlightStyle
+		want  string
+	}{
+		{HighlightText, "Stack allocation is cheap.\n"},
+		{HighlightEquals, "Stack allocation is ==cheap==.\n"},
+		{HighlightHTML, "Stack allocation is <mark>cheap</mark>.\n"},
+		{HighlightBold, "Stack allocation is **cheap**.\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithHighlightStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestLangSpans(t *testing.T) {
+	const s = `<p>The <span lang="fr" class="foreign">raison d'être</span> of pools.</p>`
+
+	if md, want := convert(s), "The raison d'être of pools.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want := "The <span lang=\"fr\">raison d'être</span> of pools.\n"
+	if md := convert(s, WithLangSpans(true)); md != want {
+		t.Errorf("lang spans: got %q, want %q", md, want)
+	}
+}
+
+func TestSetextHeadings(t *testing.T) {
+	tests := []struct {
+		html string
+		opts []Option
+		want string
+	}{
+		{"<h1>Go</h1><h2>Heap</h2><h3>Stack</h3>", nil, "Go\n===\n\nHeap\n---\n\n### Stack\n"},
+		{"<h1>Escape analysis</h1>", []Option{WithSetextUnderlineMatchWidth(true)}, "Escape analysis\n===============\n"},
+		{"<h2>内存分配</h2>", []Option{WithSetextUnderlineMatchWidth(true)}, "内存分配\n--------\n"},
+		{"<h1>Go 内存</h1>", []Option{WithSetextUnderlineMatchWidth(true)}, "Go 内存\n=======\n"},
+	}
+
+	for _, test := range tests {
+		opts := append([]Option{WithHeadingStyle(HeadingSetext)}, test.opts...)
+		if md := convert(test.html, opts...); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestHeadingStyle(t *testing.T) {
+	const s = "<h1>Go</h1><h2>Heap</h2><h3>Stack</h3>"
+
+	tests := []struct {
+		style HeadingStyle
+		want  string
+	}{
+		{HeadingATX, "# Go\n\n## Heap\n\n### Stack\n"},
+		{HeadingSetext, "Go\n===\n\nHeap\n---\n\n### Stack\n"},
+		{HeadingATXClosed, "# Go #\n\n## Heap ##\n\n### Stack ###\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithHeadingStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestHeadingBreaks(t *testing.T) {
+	const s = "<h2>Title<br>Subtitle</h2><h3>Stack<br>frames</h3>"
+
+	tests := []struct {
+		style HeadingStyle
+		want  string
+	}{
+		{HeadingATX, "## Title Subtitle\n\n### Stack frames\n"},
+		{HeadingSetext, "Title  \nSubtitle\n---\n\n### Stack frames\n"},
+		{HeadingATXClosed, "## Title Subtitle ##\n\n### Stack frames ###\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithHeadingStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestPictureSource(t *testing.T) {
+	const s = `<picture>` +
+		`<source media="(min-width: 1200px)" srcset="large.jpg 1x, large@2x.jpg 2x">` +
+		`<source media="(min-width: 600px) and (max-width: 1199px)" srcset="medium.jpg">` +
+		`<source media="print" srcset="print.jpg">` +
+		`<source srcset="default.webp" type="image/webp">` +
+		`<img src="small.jpg" alt="Heap"></picture>`
+
+	if md, want := convert(s), "![Heap](small.jpg)\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{0, "![Heap](default.webp)\n"},
+		{1600, "![Heap](large.jpg)\n"},
+		{800, "![Heap](medium.jpg)\n"},
+		{320, "![Heap](default.webp)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithPictureSource(test.width)); md != test.want {
+			t.Errorf("width %d: got %q, want %q", test.width, md, test.want)
+		}
+	}
+
+	const fallback = `<picture><source media="(min-width: 600px)" srcset="wide.jpg"><img src="small.jpg" alt="Heap"></picture>`
+	if md, want := convert(fallback, WithPictureSource(0)), "![Heap](small.jpg)\n"; md != want {
+		t.Errorf("fallback: got %q, want %q", md, want)
+	}
+}
+
+func TestCollapseWhitespace(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p>  a   b\n  c </p>", "a b c\n"},
+		{"<p>a\t\tb <br>\n   c</p>", "a b  \nc\n"},
+		{"<p>a <b> b </b>  c</p>", "a **b** c\n"},
+		{"<h2>  a \n  b </h2><ul><li>  c \n d </li></ul>", "## a b\n\n* c d\n"},
+		{"<p>a&nbsp;&nbsp;b</p>", "a\u00a0\u00a0b\n"},
+		{"<p><code>a  b</code></p><pre><code>  a\n\n  b</code></pre>", "`a  b`\n\n```\n  a\n\n  b\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestNestedWrappers(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<div>\n  <div>\n    <div>\n      <p>a</p>\n    </div>\n  </div>\n</div>\n<p>b</p>", "a\n\nb\n"},
+		{"<blockquote><div><div><div><p>a</p></div></div></div></blockquote>", "> a\n"},
+		{"<ul><li><div><div><div>a</div></div></div></li><li>b</li></ul>", "* a\n* b\n"},
+		{"<table><tr><th>h</th></tr><tr><td><div><div><div>a</div></div></div></td></tr></table>", "| h |\n| --- |\n| a |\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestCodeSpanBackticks(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<code>x</code>", "`x`\n"},
+		{"<code>a`b</code>", "``a`b``\n"},
+		{"<code>`a`</code>", "`` `a` ``\n"},
+		{"<code>``x` ```</code>", "```` ``x` ``` ````\n"},
+		{"<code> a </code>", "`  a  `\n"},
+		{"<code>  </code>", "`  `\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestBlankCodeSpans(t *testing.T) {
+	const s = "<p>Split on <code> </code>, not <code>\t</code>.</p>"
+
+	if md, want := convert(s), "Split on ` `, not `\t`.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithBlankCodeSpans(false)), "Split on , not .\n"; md != want {
+		t.Errorf("disabled: got %q, want %q", md, want)
+	}
+}
+
+func TestCodeBlockFence(t *testing.T) {
+	const (
+		plain = `<pre data-language="go"><code>x := 1</code></pre>`
+		fence = "<pre data-language=\"md\"><code>```go\nx\n```</code></pre>"
+		tilde = "<pre><code>~~~~\nx</code></pre>"
+	)
+
+	tests := []struct {
+		html string
+		char rune
+		want string
+	}{
+		{plain, '`', "```go\nx := 1\n```\n"},
+		{plain, '~', "~~~go\nx := 1\n~~~\n"},
+		{plain, '*', "```go\nx := 1\n```\n"},
+		{fence, '`', "````md\n```go\nx\n```\n````\n"},
+		{fence, '~', "~~~md\n```go\nx\n```\n~~~\n"},
+		{tilde, '~', "~~~~~\n~~~~\nx\n~~~~~\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithCodeBlockFence(test.char)); md != test.want {
+			t.Errorf("%q with %q: got %q, want %q", test.html, test.char, md, test.want)
+		}
+	}
+}
+
+func TestBlockEmphasis(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p><strong>Whole paragraph bold.</strong></p>", "**Whole paragraph bold.**\n"},
+		{"<strong><p>a</p>\n<p>b</p></strong>", "**a**\n\n**b**\n"},
+		{"<div><b>x<p>y</p></b></div>", "**x**\n\n**y**\n"},
+		{"<em><h2>Title</h2><p>a</p></em>", "## *Title*\n\n*a*\n"},
+		{"<b><ul><li>a</li>\n<li>b <i>c</i></li></ul><pre><code>x</code></pre></b>", "* **a**\n* **b *c***\n\n```\nx\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestComplexTablesAsHTML(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			`<table><tr><th colspan="2">Heap</th></tr><tr><td>a</td><td>b</td></tr></table>`,
+			"<table><tr><th colspan=\"2\">Heap</th></tr><tr><td>a</td><td>b</td></tr></table>\n",
+		},
+		{
+			`<table><tr><th>Steps</th></tr><tr><td><ul><li>a</li></ul></td></tr></table>`,
+			"<table><tr><th>Steps</th></tr><tr><td><ul><li>a</li></ul></td></tr></table>\n",
+		},
+		{
+			`<table><tr><th>a</th></tr><tr><td><p>b</p></td></tr></table>`,
+			"| a |\n| --- |\n| b |\n",
+		},
+		{
+			`<table><tr><th colspan="1">a</th></tr><tr><td>b</td></tr></table>`,
+			"| a |\n| --- |\n| b |\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithComplexTablesAsHTML(true)); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+
+	const s = `<table><tr><th colspan="2">Heap</th></tr><tr><td>a</td><td>b</td></tr></table>`
+	if md, want := convert(s), "| Heap |  |\n| --- | --- |\n| a | b |\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
+func TestItemParagraphLikeMarker(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<ol><li><p>Step</p><p>I. Intro</p></li></ol>", "1. Step\n\n   I. Intro\n"},
+		{"<ul><li><p>Call</p><p>Mr. Smith</p></li><li><p>Watch</p><p>Dr. No</p></li></ul>", "* Call\n\n  Mr. Smith\n\n* Watch\n\n  Dr. No\n"},
+		{"<ul><li><p>a</p><ol><li>b</li></ol></li></ul>", "* a\n  1. b\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithEscaping(false)); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestItemStartingWithCode(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<ul><li><pre><code>x := 1\n\ny := 2</code></pre></li><li>b</li></ul>", "* ```\n  x := 1\n\n  y := 2\n  ```\n* b\n"},
+		{`<ol><li><pre data-lang="go"><code>x</code></pre><p>after</p></li></ol>`, "1. ```go\n   x\n   ```\n\n   after\n"},
+		{"<ul><li>a<ul><li><pre><code>x\n  y</code></pre></li></ul></li></ul>", "* a\n  * ```\n    x\n      y\n    ```\n"},
+		{"<ol start=\"9\"><li><pre><code>x</code></pre></li><li><pre><code>y</code></pre></li></ol>", "9. ```\n   x\n   ```\n10. ```\n    y\n    ```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestHeadingIDs(t *testing.T) {
+	const s = `<h2 id="tools-of-the-trade">Tools of the Trade</h2><h3 id="Go_1.9">Go</h3><h3>No id</h3>`
+
+	if md, want := convert(s), "## Tools of the Trade\n\n### Go\n\n### No id\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	tests := []struct {
+		style HeadingStyle
+		want  string
+	}{
+		{HeadingATX, "## Tools of the Trade {#tools-of-the-trade}\n\n### Go {#Go_1.9}\n\n### No id\n"},
+		{HeadingSetext, "Tools of the Trade {#tools-of-the-trade}\n---\n\n### Go {#Go_1.9}\n\n### No id\n"},
+		{HeadingATXClosed, "## Tools of the Trade ## {#tools-of-the-trade}\n\n### Go ### {#Go_1.9}\n\n### No id ###\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithHeadingIDs(true), WithHeadingStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestSpacerParagraphs(t *testing.T) {
+	const s = "<p>Allocate less.</p><p><br></p><p>Reuse buffers.<br></p>"
+
+	if md, want := convert(s), "Allocate less.\n\nReuse buffers.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want := "Allocate less.\n\n&nbsp;\n\nReuse buffers.\n"
+	if md := convert(s, WithSpacerParagraphs(true)); md != want {
+		t.Errorf("spacers: got %q, want %q", md, want)
+	}
+}
+
+func TestKeepAttributes(t *testing.T) {
+	const s = `<p>Call <code class="language-go">sync.Pool.Get()</code> or <code>new(T)</code>.</p>`
+
+	if md, want := convert(s), "Call `sync.Pool.Get()` or `new(T)`.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	want := "Call <code class=\"language-go\">sync.Pool.Get()</code> or `new(T)`.\n"
+	if md := convert(s, WithKeepAttributes(true)); md != want {
+		t.Errorf("keep attributes: got %q, want %q", md, want)
+	}
+}
+
+func TestWrapInCodeFence(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<h2>Pools</h2><p>Use <code>sync.Pool</code>.</p>", "```md\n## Pools\n\nUse `sync.Pool`.\n```\n"},
+		{"<pre><code>b.Reset()</code></pre>", "````md\n```\nb.Reset()\n```\n````\n"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, WithWrapInCodeFence("md")); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestLinkTextPadding(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<p>Read<a href="/pools"> the pool docs </a>first.</p>`, "Read [the pool docs](/pools) first.\n"},
+		{"<p>Read <a href=\"/pools\">\tdocs\t</a>.</p>", "Read [docs](/pools) .\n"},
+		{`<p><a href="/pools"> docs</a></p>`, "[docs](/pools)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestKeepDetails(t *testing.T) {
+	const s = "<details open><summary>Benchmark <b>results</b></summary><p>3 allocs/op</p></details>" +
+		"<details><summary>Setup</summary></details>"
+
+	want := "<details open>\n<summary>Benchmark **results**</summary>\n\n3 allocs/op\n\n</details>\n\n" +
+		"<details>\n<summary>Setup</summary>\n</details>\n"
+	if md := convert(s, WithKeepDetails(true)); md != want {
+		t.Errorf("keep details: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s), "Benchmark **results**\n\n3 allocs/op\n\nSetup\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
+func TestSingleDefinition(t *testing.T) {
+	const s = "<dl><dt>GOGC</dt><dd>Sets the GC target.</dd><dd>Defaults to 100.</dd><dd>off disables the GC.</dd>" +
+		"<dt>GOMEMLIMIT</dt><dd>Sets a soft memory limit.</dd></dl>"
+
+	want := "GOGC\n: Sets the GC target.  \n  Defaults to 100.  \n  off disables the GC.\n\n" +
+		"GOMEMLIMIT\n: Sets a soft memory limit.\n"
+	if md := convert(s, WithDefinitionStyle(DefinitionSingle)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestEmptyHref(t *testing.T) {
+	const s = `<p>See <a href="" title="sync.Pool docs">pools</a> and <a title="escape analysis">escapes</a>.</p>`
+
+	if md, want := convert(s), "See pools and escapes.\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithEmptyLinkTarget("#")), "See [pools](# \"sync.Pool docs\") and [escapes](# \"escape analysis\").\n"; md != want {
+		t.Errorf("with target: got %q, want %q", md, want)
+	}
+}
+
+func TestBlockquote(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<blockquote><p>a</p><p>b</p></blockquote>", "> a\n>\n> b\n"},
+		{"<blockquote><ul><li>x</li><li>y</li></ul></blockquote>", "> * x\n> * y\n"},
+		{"<blockquote><p>n</p><blockquote><p>m</p><p>o</p></blockquote></blockquote>", "> n\n>\n> > m\n> >\n> > o\n"},
+		{
+			"<blockquote><pre><code>x := 1\n\ny()</code></pre></blockquote>",
+			"> ```\n> x := 1\n>\n> y()\n> ```\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestChoiceLists(t *testing.T) {
+	const s = `<fieldset><legend>GC mode</legend>` +
+		`<label><input type="radio" name="gc" value="default"> Default</label><br>` +
+		`<input type="radio" name="gc" id="off" value="off" checked><label for="off">GOGC=off</label><br>` +
+		`<input type="radio" name="gc" value="limit"></fieldset>`
+
+	want := "GC mode\n\n* [ ] Default\n* [x] GOGC=off\n* [ ] limit\n"
+	if md := convert(s, WithChoiceLists(true)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	const blank = `<fieldset><legend> </legend><input type="checkbox" value="x"></fieldset>`
+	if md, want := convert(blank, WithChoiceLists(true)), "* [ ] x\n"; md != want {
+		t.Errorf("blank legend: got %q, want %q", md, want)
+	}
+}
+
+func TestNestedAnchors(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<p><a href="/pool">sync <a href="/get">Get</a> and Put</a></p>`, "[sync](/pool) [Get](/get) and Put\n"},
+		{`<p><a href="/pool">sync <b>Pool <a href="/get">Get</a></b></a></p>`, "[sync **Pool**](/pool) [Get](/get)\n"},
+		{`<a href="/pool"><table><tr><td><a href="/get">Get</a></td></tr></table></a>`, "[Get](/pool)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestSVGStyle(t *testing.T) {
+	const s = `<p>Go <svg width="16" data-src="/gopher.svg"><title>Gopher</title><path d="M0 0h16v16"/></svg> mascot</p>`
+
+	tests := []struct {
+		style SVGStyle
+		want  string
+	}{
+		{SVGDrop, "Go mascot\n"},
+		{SVGHTML, `Go <svg width="16" data-src="/gopher.svg"><title>Gopher</title><path d="M0 0h16v16"></path></svg> mascot` + "\n"},
+		{SVGImage, "Go ![Gopher](/gopher.svg) mascot\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithSVGStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+
+	if md, want := convert("<p>Go <svg><path/></svg></p>", WithSVGStyle(SVGImage)), "Go\n"; md != want {
+		t.Errorf("image without data-src: got %q, want %q", md, want)
+	}
+}
+
+func TestEmojiStyle(t *testing.T) {
+	const s = `<p>Nice <img class="emoji" alt="😀" src="/e/1f600.png" title=":grinning:"> and ` +
+		`<img class="wp-smiley emoji" alt="🚀" src="/e/1f680.png"> <img alt="logo" src="logo.png"></p>`
+
+	tests := []struct {
+		style EmojiStyle
+		want  string
+	}{
+		{EmojiImage, "Nice ![😀](/e/1f600.png \":grinning:\") and ![🚀](/e/1f680.png) ![logo](logo.png)\n"},
+		{EmojiUnicode, "Nice 😀 and 🚀 ![logo](logo.png)\n"},
+		{EmojiShortcode, "Nice :grinning: and 🚀 ![logo](logo.png)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithEmojiStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestBlockquoteAttribution(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{
+			"<blockquote><p>Clear is better than clever.</p><footer>— <cite>Rob Pike</cite></footer></blockquote>",
+			"> Clear is better than clever.\n>\n> — Rob Pike\n",
+		},
+		{
+			"<blockquote>Don't panic.<cite>Go Proverbs</cite></blockquote>",
+			"> Don't panic.\n>\n> — Go Proverbs\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestDefinitionStyle(t *testing.T) {
+	const s = "<dl><dt>GOGC</dt><dd>Sets the GC target.</dd><dd><p>Defaults to 100.</p><p>off disables it.</p></dd>" +
+		"<dt>Heap</dt><dt>Arena</dt><dd>Where escaping values live.</dd></dl>"
+
+	tests := []struct {
+		style DefinitionStyle
+		want  string
+	}{
+		{
+			DefinitionPandoc,
+			"GOGC\n:   Sets the GC target.\n:   Defaults to 100.\n\n    off disables it.\n\n" +
+				"Heap\nArena\n:   Where escaping values live.\n",
+		},
+		{
+			DefinitionBold,
+			"**GOGC**\n\n  Sets the GC target.\n\n  Defaults to 100.\n\n  off disables it.\n\n" +
+				"**Heap**  \n**Arena**\n\n  Where escaping values live.\n",
+		},
+		{
+			DefinitionText,
+			"GOGC\n\nSets the GC target.\n\nDefaults to 100.\n\noff disables it.\n\nHeap\n\nArena\n\nWhere escaping values live.\n",
+		},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithDefinitionStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+
+	if md := convert(s); md != tests[0].want {
+		t.Errorf("default: got %q, want %q", md, tests[0].want)
+	}
+}
+
+func TestBlockquoteHeadings(t *testing.T) {
+	tests := []struct {
+		html string
+		opts []Option
+		want string
+	}{
+		{"<blockquote><h2>Tip</h2><p>Reuse buffers.</p></blockquote>", nil, "> ## Tip\n>\n> Reuse buffers.\n"},
+		{"<blockquote><blockquote><h3>Tip</h3></blockquote></blockquote>", nil, "> > ### Tip\n"},
+		{"<blockquote><h1>Tip</h1><p>Reuse buffers.</p></blockquote>", []Option{WithHeadingStyle(HeadingSetext)}, "> Tip\n> ===\n>\n> Reuse buffers.\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html, test.opts...); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestBreakStyle(t *testing.T) {
+	const s = "<p><br>Get<br/>Put <b>Reset<br></b></p><table><tr><th>a<br>b</th></tr></table>"
+
+	tests := []struct {
+		style BreakStyle
+		want  string
+	}{
+		{BreakSpaces, "Get  \nPut **Reset**\n\n| a b |\n| --- |\n"},
+		{BreakBackslash, "Get\\\nPut **Reset**\n\n| a b |\n| --- |\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithBreakStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestParagraphWithBlocks(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p>Pools <b>help</b><div>Reuse <p>buffers</p></div> often.</p>", "Pools **help**\n\nReuse\n\nbuffers\n\noften.\n"},
+		{"<p>Pools<center>help</center>often.</p>", "Pools\n\nhelp\n\noften.\n"},
+		{"<p>Pools<noscript>help</noscript></p>", "Pools\n\nhelp\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestLinkTitle(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<a href="/pool" title="sync.Pool">pools</a>`, "[pools](/pool \"sync.Pool\")\n"},
+		{`<a href="/pool" title="The &quot;Pool&quot;&#10;  type">pools</a>`, "[pools](/pool \"The \\\"Pool\\\" type\")\n"},
+		{`<a href="/pool" title=" ">pools</a>`, "[pools](/pool)\n"},
+		{`<a href="/pool">pools</a>`, "[pools](/pool)\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestQuoteStyle(t *testing.T) {
+	const s = "<p>He said <q>she wrote <q>no</q> twice</q>.</p>"
+
+	tests := []struct {
+		style QuoteStyle
+		want  string
+	}{
+		{QuoteStraight, "He said \"she wrote 'no' twice\".\n"},
+		{QuoteCurly, "He said “she wrote ‘no’ twice”.\n"},
+		{QuoteGuillemets, "He said «she wrote “no” twice».\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(s, WithQuoteStyle(test.style)); md != test.want {
+			t.Errorf("style %d: got %q, want %q", test.style, md, test.want)
+		}
+	}
+}
+
+func TestSectionBreaks(t *testing.T) {
+	const s = "<section><h2>A</h2><p>a</p></section>\n<section><h2>B</h2><p>b</p></section>"
+
+	if md, want := convert(s), "## A\n\na\n\n## B\n\nb\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s, WithSectionBreaks(true)), "## A\n\na\n\n* * *\n\n## B\n\nb\n"; md != want {
+		t.Errorf("breaks: got %q, want %q", md, want)
+	}
+
+	const nested = "<section><p>a</p><section><p>x</p></section><section><p>y</p></section></section><p>c</p><section><p>b</p></section>"
+	if md, want := convert(nested, WithSectionBreaks(true)), "a\n\nx\n\ny\n\nc\n\nb\n"; md != want {
+		t.Errorf("nested: got %q, want %q", md, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p>a * b</p>", "a \\* b\n"},
+		{"<p>snake_case _x_ [x] `x` C:\\dir a\\*</p>", "snake_case \\_x\\_ \\[x\\] \\`x\\` C:\\dir a\\\\\\*\n"},
+		{"<p>1. first</p><p># title</p><p>#tag</p><p>&gt; quote</p>", "1\\. first\n\n\\# title\n\n#tag\n\n\\> quote\n"},
+		{"<p>- a<br>\n+ b<br>---</p>", "\\- a  \n\\+ b  \n\\---\n"},
+		{"<p><b>1.</b> 2. &lt;br&gt;</p>", "**1\\.** 2. \\<br>\n"},
+		{"<p>a <code>*x*</code></p><pre><code>*x*</code></pre>", "a `*x*`\n\n```\n*x*\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+
+	const decorative = `<p><img src="x.png" alt=""> 1. a</p>`
+	if md, want := convert(decorative, WithDropDecorativeImages(true)), "1\\. a\n"; md != want {
+		t.Errorf("after dropped image: got %q, want %q", md, want)
+	}
+
+	if md, want := convert("<p>1. a * b</p>", WithEscaping(false)), "1. a * b\n"; md != want {
+		t.Errorf("disabled: got %q, want %q", md, want)
+	}
+}
+
+func TestCharacterReferences(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<p>a &amp; b &#39;c&#39; &quot;d&quot; e&nbsp;f</p>", "a & b 'c' \"d\" e\u00a0f\n"},
+		{"<p>&amp;copy; &amp;#169; &amp;#xA9; AT&amp;T;</p>", "&amp;copy; &amp;#169; &amp;#xA9; AT&amp;T;\n"},
+		{"<p><code>&amp;copy;</code></p><pre><code>x &amp;&amp; y</code></pre>", "`&copy;`\n\n```\nx && y\n```\n"},
+	}
+
+	for _, test := range tests {
+		if md := convert(test.html); md != test.want {
+			t.Errorf("%q: got %q, want %q", test.html, md, test.want)
+		}
+	}
+}
+
+func TestStraightQuotes(t *testing.T) {
+	const s = "<p>“Don’t” – allocate—unless… <code>s := “x”</code></p>"
+
+	want := "\"Don't\" - allocate--unless... `s := “x”`\n"
+	if md := convert(s, WithStraightQuotes(true)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	if md, want := convert(s), "“Don’t” – allocate—unless… `s := “x”`\n"; md != want {
+		t.Errorf("default: got %q, want %q", md, want)
+	}
+}
+
 // Test: https://segment.com/blog/allocation-efficiency-in-high-performance-go-services/
 
 func TestParseHTMLtoMD(t *testing.T) {
-	f, err := os.OpenFile("Allocation Efficiency in High-Performance Go Services.md", os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := os.OpenFile("Allocation Efficiency in High-Performance Go Services.md", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		panic(err)
 	}
 
-	var title = "# Allocation Efficiency in High-Performance Go Services\n"
+	var title = "# Allocation Efficiency in High-Performance Go Services\n\n"
 	n, err := f.Write([]byte(title))
 	if len(title) == n && err != nil {
 		panic(err)
@@ -0,0 +1,103 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"strconv"
+	"strings"
+)
+
+// linkRefs numbers the destinations of links written apart from their text,
+// in the order they are first linked to.
+type linkRefs struct {
+	ids  map[string][]int
+	defs []linkDef
+}
+
+// linkDef is a numbered destination and the title of the links to it.
+type linkDef struct {
+	dest, title string
+}
+
+// link writes a link to dest, which may be followed by a title, in the
+// configured LinkStyle.
+func (r *renderer) link(text, dest, title string) string {
+	if r.opts.linkStyle == LinkInline {
+		return "[" + text + "](" + dest + title + ")"
+	}
+
+	if r.refs == nil {
+		r.refs = &linkRefs{ids: make(map[string][]int)}
+	}
+	id := r.refs.number(dest, title)
+
+	if r.opts.linkStyle == LinkReference {
+		return "[" + text + "][" + strconv.Itoa(id) + "]"
+	}
+	return text + "\\[" + strconv.Itoa(id) + "\\]"
+}
+
+// number returns the number of dest with title. A link without a title
+// shares any number of its destination, and a titled one fills in the title
+// of an untitled number; only different titles get numbers of their own.
+func (r *linkRefs) number(dest, title string) int {
+	for _, id := range r.ids[dest] {
+		if def := &r.defs[id-1]; def.title == title || title == "" {
+			return id
+		}
+	}
+	for _, id := range r.ids[dest] {
+		if def := &r.defs[id-1]; def.title == "" {
+			def.title = title
+			return id
+		}
+	}
+
+	r.defs = append(r.defs, linkDef{dest, title})
+	id := len(r.defs)
+	r.ids[dest] = append(r.ids[dest], id)
+	return id
+}
+
+// mdlinkrefs lists the destinations of the links, [1]: url. Footnotes are
+// printed as plain text, one to a line, where link definitions would not
+// show.
+func (r *renderer) mdlinkrefs() string {
+	open, close, sep := "[", "]: ", "\n"
+	if r.opts.linkStyle == LinkFootnote {
+		open, close, sep = "\\[", "\\]: ", r.hardBreak()
+	}
+
+	lines := make([]string, len(r.refs.defs))
+	for i, def := range r.refs.defs {
+		lines[i] = open + strconv.Itoa(i+1) + close + def.dest + def.title
+	}
+	return strings.Join(lines, sep)
+}
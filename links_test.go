@@ -0,0 +1,54 @@
+package html2md
+
+import (
+	"testing"
+)
+
+func TestFootnoteLinks(t *testing.T) {
+	const s = `<p>See <a href="https://go.dev/doc/gc-guide" title="GC guide">the guide</a> and ` +
+		`<a href="/pool">pools</a>.</p><p>Read <a href="https://go.dev/doc/gc-guide">it</a> twice.</p>`
+
+	want := "See the guide\\[1\\] and pools\\[2\\].\n\nRead it\\[1\\] twice.\n\n" +
+		"\\[1\\]: https://go.dev/doc/gc-guide \"GC guide\"  \n\\[2\\]: /pool\n"
+	if md := convert(s, WithLinkStyle(LinkFootnote)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestReferenceLinks(t *testing.T) {
+	const s = `<p>See <a href="https://go.dev/doc/gc-guide" title="GC guide">the guide</a>, ` +
+		`<a href="/pool">pools</a> and <a href="https://go.dev/doc/gc-guide">the guide</a> again.</p>` +
+		`<ul><li><a href="/arena"><img src="arena.png" alt="arenas"></a></li></ul>`
+
+	want := "See [the guide][1], [pools][2] and [the guide][1] again.\n\n* [![arenas](arena.png)][3]\n\n" +
+		"[1]: https://go.dev/doc/gc-guide \"GC guide\"\n[2]: /pool\n[3]: /arena\n"
+	for i := 0; i < 3; i++ {
+		if md := convert(s, WithLinkStyle(LinkReference)); md != want {
+			t.Errorf("got %q, want %q", md, want)
+		}
+	}
+}
+
+func TestReferenceLinkTitles(t *testing.T) {
+	const s = `<p><a href="/pool">one</a> <a href="/pool" title="Pools">two</a> ` +
+		`<a href="/pool" title="Arenas">three</a> <a href="/pool">four</a></p>`
+
+	want := "[one][1] [two][1] [three][2] [four][1]\n\n[1]: /pool \"Pools\"\n[2]: /pool \"Arenas\"\n"
+	if md := convert(s, WithLinkStyle(LinkReference)); md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+}
+
+func TestTelLinks(t *testing.T) {
+	const s = `<p>Call <a href="tel:+1-555-0100">+1 555 0100</a> or <a href="TEL:+1 555 0199">the desk</a>.</p>`
+
+	want := "Call [+1 555 0100](tel:+1-555-0100) or [the desk](<tel:+1 555 0199>).\n"
+	if md := convert(s, WithBaseURL("https://example.com/blog/")); md != want {
+		t.Errorf("links: got %q, want %q", md, want)
+	}
+
+	want = "Call +1 555 0100 or the desk.\n"
+	if md := convert(s, WithPlainTelLinks(true)); md != want {
+		t.Errorf("plain: got %q, want %q", md, want)
+	}
+}
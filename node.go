@@ -0,0 +1,361 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"html"
+	"strings"
+)
+
+type nodeType int
+
+const (
+	elementNode nodeType = iota
+	textNode
+)
+
+// node is an element or a piece of text in the parsed html tree.
+type node struct {
+	typ      nodeType
+	tag      string
+	data     string
+	attrs    []attribute
+	parent   *node
+	children []*node
+}
+
+var (
+	voidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+
+	// closesP holds the start tags that end an open <p>.
+	closesP = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "center": true, "details": true,
+		"dialog": true, "div": true, "hgroup": true, "summary": true,
+		"dl": true, "dd": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+		"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+		"hr": true, "li": true, "main": true, "menu": true, "nav": true, "ol": true, "p": true, "pre": true,
+		"section": true, "table": true, "ul": true,
+	}
+
+	// scopeElements stop the search for an open element to close.
+	scopeElements = map[string]bool{
+		"table": true, "td": true, "th": true, "caption": true, "button": true, "object": true, "template": true,
+	}
+
+	tableElements = map[string]bool{
+		"tbody": true, "thead": true, "tfoot": true, "tr": true, "td": true, "th": true, "caption": true,
+	}
+)
+
+func (n *node) attr(key string) string {
+	for _, a := range n.attrs {
+		if a.key == key {
+			return a.val
+		}
+	}
+	return ""
+}
+
+func (n *node) hasAttr(key string) bool {
+	return hasAttr(n.attrs, key)
+}
+
+// style returns the value of the css property prop in the style attribute
+// of n, in lower case.
+func (n *node) style(prop string) string {
+	for _, decl := range strings.Split(n.attr("style"), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), prop) {
+			return strings.ToLower(strings.TrimSpace(kv[1]))
+		}
+	}
+	return ""
+}
+
+// startTag returns the html start tag of n with its attributes.
+func (n *node) startTag() string {
+	var b strings.Builder
+	b.WriteString("<" + n.tag)
+	for _, a := range n.attrs {
+		b.WriteString(" " + a.key)
+		if a.val != "" {
+			b.WriteString(`="` + html.EscapeString(a.val) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// outerHTML returns n and its descendants as html.
+func (n *node) outerHTML() string {
+	if n.typ == textNode {
+		return html.EscapeString(n.data)
+	}
+
+	var b strings.Builder
+	b.WriteString(n.startTag())
+	if voidElements[n.tag] {
+		return b.String()
+	}
+	for _, child := range n.children {
+		b.WriteString(child.outerHTML())
+	}
+	b.WriteString("</" + n.tag + ">")
+	return b.String()
+}
+
+// hasClass reports whether class is one of the classes of n.
+func (n *node) hasClass(class string) bool {
+	for _, c := range strings.Fields(n.attr("class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node) isElement(tags ...string) bool {
+	if n.typ != elementNode {
+		return false
+	}
+	for _, tag := range tags {
+		if n.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// inside reports whether an ancestor of n is named one of tags.
+func (n *node) inside(tags ...string) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.isElement(tags...) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyChild returns the only child of n if it is an element named tag,
+// ignoring whitespace around it, or nil.
+func onlyChild(n *node, tag string) *node {
+	var only *node
+	for _, child := range n.children {
+		switch {
+		case child.typ == textNode && strings.TrimSpace(child.data) == "":
+		case child.isElement(tag) && only == nil:
+			only = child
+		default:
+			return nil
+		}
+	}
+	return only
+}
+
+// childElements returns the children of n named tag.
+func childElements(n *node, tag string) []*node {
+	var elements []*node
+	for _, child := range n.children {
+		if child.isElement(tag) {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// find returns the first element named tag in n and its descendants, in
+// document order, or nil.
+func find(n *node, tag string) *node {
+	if n.isElement(tag) {
+		return n
+	}
+	for _, child := range n.children {
+		if found := find(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// text returns the text content of n and its descendants.
+func (n *node) text() string {
+	if n.typ == textNode {
+		return n.data
+	}
+
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(child.text())
+	}
+	return b.String()
+}
+
+func (n *node) appendChild(child *node) {
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+func (n *node) appendText(s string) {
+	if len(n.children) > 0 {
+		if last := n.children[len(n.children)-1]; last.typ == textNode {
+			last.data += s
+			return
+		}
+	}
+	n.appendChild(&node{typ: textNode, data: s})
+}
+
+// parse builds a node tree from html. Missing end tags are implied the way
+// browsers do for the common cases, and stray end tags are dropped.
+func parse(s string) *node {
+	root, _ := parseProblems(s)
+	return root
+}
+
+// parseProblems is like parse but also reports the stray end tags it drops.
+func parseProblems(s string) (*node, []Problem) {
+	return parseTokens(newTokenizer(s))
+}
+
+// parseTokens builds a node tree from the tokens of z, reporting the stray
+// end tags it drops.
+func parseTokens(z *tokenizer) (*node, []Problem) {
+	var (
+		root     = &node{typ: elementNode}
+		stack    = []*node{root}
+		problems []Problem
+	)
+
+	for {
+		tok, ok := z.next()
+		if !ok {
+			return root, problems
+		}
+
+		switch tok.typ {
+		case textToken:
+			stack[len(stack)-1].appendText(tok.data)
+		case startTagToken:
+			stack = closeImplied(stack, tok.data)
+			n := &node{typ: elementNode, tag: tok.data, attrs: tok.attrs}
+			stack[len(stack)-1].appendChild(n)
+			if !voidElements[n.tag] && !tok.selfClosing {
+				stack = append(stack, n)
+			}
+		case endTagToken:
+			stops := scopeElements
+			switch {
+			case tok.data == "table":
+				stops = nil
+			case tableElements[tok.data]:
+				stops = map[string]bool{"table": true}
+			}
+			if i := lookup(stack, []string{tok.data}, stops); i > 0 {
+				stack = stack[:i]
+			} else {
+				problems = append(problems, Problem{
+					Tag:  tok.data,
+					Line: z.line(tok.offset),
+					Msg:  "unexpected end tag </" + tok.data + ">",
+				})
+			}
+		}
+	}
+}
+
+// closeImplied pops the elements that the start tag implicitly ends.
+func closeImplied(stack []*node, tag string) []*node {
+	var (
+		targets []string
+		stops   map[string]bool
+	)
+
+	switch tag {
+	case "li":
+		targets, stops = []string{"li"}, scope("ul", "ol")
+	case "dt", "dd":
+		targets, stops = []string{"dt", "dd"}, scope("dl")
+	case "tr":
+		targets, stops = []string{"tr"}, scope("thead", "tbody", "tfoot")
+	case "td", "th":
+		targets, stops = []string{"td", "th"}, scope("tr")
+	case "thead", "tbody", "tfoot":
+		targets, stops = []string{"thead", "tbody", "tfoot"}, map[string]bool{"table": true}
+	case "option":
+		targets, stops = []string{"option"}, scope("select")
+	case "a":
+		targets, stops = []string{"a"}, scopeElements
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if top := stack[len(stack)-1]; top.isElement("h1", "h2", "h3", "h4", "h5", "h6") {
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if i := lookup(stack, targets, stops); i > 0 {
+		stack = stack[:i]
+	}
+
+	if closesP[tag] {
+		if i := lookup(stack, []string{"p"}, scopeElements); i > 0 {
+			stack = stack[:i]
+		}
+	}
+
+	return stack
+}
+
+// lookup returns the index of the innermost open element named in targets,
+// or -1 if an element in stops is reached first.
+func lookup(stack []*node, targets []string, stops map[string]bool) int {
+	for i := len(stack) - 1; i > 0; i-- {
+		if stack[i].isElement(targets...) {
+			return i
+		}
+		if stops[stack[i].tag] {
+			return -1
+		}
+	}
+	return -1
+}
+
+// scope returns scopeElements extended with tags.
+func scope(tags ...string) map[string]bool {
+	m := make(map[string]bool, len(scopeElements)+len(tags))
+	for tag := range scopeElements {
+		m[tag] = true
+	}
+	for _, tag := range tags {
+		m[tag] = true
+	}
+	return m
+}
@@ -0,0 +1,780 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"net/url"
+)
+
+// DiffStyle selects how <del> and <ins> are converted.
+type DiffStyle int
+
+const (
+	// DiffText converts <del> into ~~text~~ and keeps the plain text of <ins>.
+	DiffText DiffStyle = iota
+	// DiffMarkers converts <del> into ~~text~~ and <ins> into **text**.
+	DiffMarkers
+	// DiffHTML keeps both <del> and <ins> as raw html.
+	DiffHTML
+)
+
+// DestinationStyle selects how urls that cannot appear in md as they are,
+// such as ones with spaces or parentheses, are written.
+type DestinationStyle int
+
+const (
+	// DestinationAngle wraps the url in angle brackets: [text](<a b>).
+	DestinationAngle DestinationStyle = iota
+	// DestinationEncode percent-encodes the offending characters: [text](a%20b).
+	DestinationEncode
+)
+
+// DownloadStyle selects how links with a download attribute are converted.
+type DownloadStyle int
+
+const (
+	// DownloadLink converts them like any other link.
+	DownloadLink DownloadStyle = iota
+	// DownloadAnnotate appends " (download)" to the link text.
+	DownloadAnnotate
+	// DownloadHTML keeps the <a> as raw html so the attribute survives.
+	DownloadHTML
+)
+
+// HighlightStyle selects how <mark> is converted.
+type HighlightStyle int
+
+const (
+	// HighlightText keeps only the text.
+	HighlightText HighlightStyle = iota
+	// HighlightEquals writes ==text==, understood by many md extensions.
+	HighlightEquals
+	// HighlightHTML keeps <mark>text</mark> as raw html.
+	HighlightHTML
+	// HighlightBold writes **text** for renderers with neither.
+	HighlightBold
+)
+
+// HeadingStyle selects how headings are written.
+type HeadingStyle int
+
+const (
+	// HeadingATX writes # text.
+	HeadingATX HeadingStyle = iota
+	// HeadingSetext underlines <h1> with === and <h2> with ---. Lower levels
+	// have no Setext form and stay ATX.
+	HeadingSetext
+	// HeadingATXClosed writes # text # with closing marks matching the
+	// opening ones.
+	HeadingATXClosed
+)
+
+// ScriptStyle selects how <sup> and <sub> are converted.
+type ScriptStyle int
+
+const (
+	// ScriptText keeps only the text.
+	ScriptText ScriptStyle = iota
+	// ScriptHTML keeps <sup>text</sup> and <sub>text</sub> as raw html.
+	ScriptHTML
+	// ScriptCaret writes ^text^ and ~text~, as pandoc does.
+	ScriptCaret
+)
+
+// DefinitionStyle selects how definition lists are converted.
+type DefinitionStyle int
+
+const (
+	// DefinitionPandoc writes each term on a line of its own followed by
+	// its definitions, each starting with a colon, as pandoc reads them.
+	DefinitionPandoc DefinitionStyle = iota
+	// DefinitionBold writes each term in bold followed by its definitions as
+	// indented paragraphs, for CommonMark renderers.
+	DefinitionBold
+	// DefinitionSingle writes a term followed by a single : definition that
+	// holds all its <dd> joined by hard line breaks.
+	DefinitionSingle
+	// DefinitionText writes terms and definitions as plain paragraphs.
+	DefinitionText
+)
+
+// SVGStyle selects how inline <svg> is converted.
+type SVGStyle int
+
+const (
+	// SVGDrop leaves it out.
+	SVGDrop SVGStyle = iota
+	// SVGHTML keeps it as raw html, which some renderers show.
+	SVGHTML
+	// SVGImage links the copy named by its data-src attribute as an image,
+	// and leaves it out when there is none.
+	SVGImage
+)
+
+// EmojiStyle selects how emoji images, <img class="emoji">, are converted.
+type EmojiStyle int
+
+const (
+	// EmojiImage converts them like any other image.
+	EmojiImage EmojiStyle = iota
+	// EmojiUnicode writes their alt text, the emoji character itself.
+	EmojiUnicode
+	// EmojiShortcode writes their :shortcode: from the title, alt or
+	// data-shortcode attribute, falling back to the alt text.
+	EmojiShortcode
+)
+
+// ListSpacing selects whether list items are separated by blank lines.
+type ListSpacing int
+
+const (
+	// ListAuto makes a list loose, with blank lines between items, when any
+	// item holds several blocks, and tight otherwise.
+	ListAuto ListSpacing = iota
+	// ListTight never puts blank lines between items.
+	ListTight
+	// ListLoose always puts blank lines between items.
+	ListLoose
+)
+
+// BreakStyle selects how <br> is written.
+type BreakStyle int
+
+const (
+	// BreakSpaces ends the line with two spaces.
+	BreakSpaces BreakStyle = iota
+	// BreakBackslash ends the line with a backslash, which editors do not
+	// strip as trailing whitespace.
+	BreakBackslash
+)
+
+// LinkStyle selects where the destinations of links are written.
+type LinkStyle int
+
+const (
+	// LinkInline writes them next to the text: [text](url).
+	LinkInline LinkStyle = iota
+	// LinkFootnote writes the text followed by a number, text[1], and lists
+	// the numbered destinations at the end as plain lines, as in print.
+	LinkFootnote
+	// LinkReference writes reference links, [text][1], defined at the end
+	// of the document.
+	LinkReference
+)
+
+// CaptionStyle selects where the caption of a figure is written.
+type CaptionStyle int
+
+const (
+	// CaptionLine writes the caption of an image in italics on the line
+	// right below it, after a hard line break. Captions of tables, code and
+	// other blocks get a paragraph of their own.
+	CaptionLine CaptionStyle = iota
+	// CaptionParagraph writes the caption in italics as a paragraph of its
+	// own after the figure.
+	CaptionParagraph
+)
+
+// QuoteStyle selects the quotation marks written around a <q>.
+type QuoteStyle int
+
+const (
+	// QuoteStraight writes "text", and 'text' when nested.
+	QuoteStraight QuoteStyle = iota
+	// QuoteCurly writes “text”, and ‘text’ when nested, as in English.
+	QuoteCurly
+	// QuoteGuillemets writes «text», and “text” when nested, as in French.
+	QuoteGuillemets
+)
+
+// Option configures how html is converted into md.
+type Option func(*options)
+
+type options struct {
+	altCaption     int
+	baseURL        *url.URL
+	imageBaseURL   *url.URL
+	keepRelative   func(href string) bool
+	diffStyle      DiffStyle
+	headingSlugs   bool
+	paraPrefix     string
+	reversed       bool
+	destStyle      DestinationStyle
+	mergeQuotes    bool
+	trimCode       bool
+	dropFragment   bool
+	mathClass      string
+	zeroWidth      bool
+	styles         bool
+	doubleCode     bool
+	headingBlank   bool
+	emptyCell      string
+	download       DownloadStyle
+	demoteHeadings bool
+	codeAutolinks  bool
+	quoteCite      bool
+	highlight      HighlightStyle
+	langSpans      bool
+	headingStyle   HeadingStyle
+	setextWidth    bool
+	spacers        bool
+	keepAttrs      bool
+	wrapFence      bool
+	wrapLang       string
+	keepDetails    bool
+	olDelimiter    string
+	scripts        ScriptStyle
+	definitions    DefinitionStyle
+	emptyTarget    string
+	maxAlt         int
+	strikethrough  bool
+	choiceLists    bool
+	svg            SVGStyle
+	emoji          EmojiStyle
+	listIndent     int
+	typeMarkers    bool
+	listSpacing    ListSpacing
+	breakStyle     BreakStyle
+	linkStyle      LinkStyle
+	dropDecorative bool
+	straightQuotes bool
+	captionStyle   CaptionStyle
+	escape         bool
+	sectionBreaks  bool
+	quoteStyle     QuoteStyle
+	headingIDs     bool
+	complexTables  bool
+	fence          string
+	blankCode      bool
+	plainTel       bool
+	pictures       bool
+	pictureWidth   int
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		trimCode: true, zeroWidth: true, headingBlank: true, strikethrough: true, escape: true, blankCode: true,
+		olDelimiter: ".", fence: "`",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithAltCaption emits the alt text of an image as an italic caption line
+// beneath it when the alt text is at least n characters long. Images in
+// links, headings and table cells get no caption. A value of n less than or
+// equal to zero disables captions, which is the default.
+func WithAltCaption(n int) Option {
+	return func(o *options) {
+		o.altCaption = n
+	}
+}
+
+// WithBaseURL resolves relative link and image urls against base. An
+// invalid base leaves urls untouched.
+func WithBaseURL(base string) Option {
+	return func(o *options) {
+		o.baseURL, _ = url.Parse(base)
+	}
+}
+
+// WithImageBaseURL resolves relative image urls against base instead of
+// the one set by WithBaseURL, for images served from another host.
+func WithImageBaseURL(base string) Option {
+	return func(o *options) {
+		o.imageBaseURL, _ = url.Parse(base)
+	}
+}
+
+// WithKeepRelative leaves an url relative, even when a base url is set, if
+// keep reports true for it.
+func WithKeepRelative(keep func(href string) bool) Option {
+	return func(o *options) {
+		o.keepRelative = keep
+	}
+}
+
+// WithDiffStyle sets how <del> and <ins> are converted, DiffText by default.
+func WithDiffStyle(style DiffStyle) Option {
+	return func(o *options) {
+		o.diffStyle = style
+	}
+}
+
+// WithHeadingSlugs points fragment links that target a heading id at the
+// slug md renderers generate from the heading text, so intra-document links
+// keep working once the html ids are gone.
+func WithHeadingSlugs(enable bool) Option {
+	return func(o *options) {
+		o.headingSlugs = enable
+	}
+}
+
+// WithParagraphPrefix starts every line of a paragraph with prefix, for
+// instance to indent the converted md under a list item. Paragraphs inside
+// lists, quotes and tables, and code blocks, are left alone.
+func WithParagraphPrefix(prefix string) Option {
+	return func(o *options) {
+		o.paraPrefix = prefix
+	}
+}
+
+// WithReversedLists numbers the items of <ol reversed> downwards. md has no
+// reversed lists, so by default they are numbered upwards like any other.
+func WithReversedLists(enable bool) Option {
+	return func(o *options) {
+		o.reversed = enable
+	}
+}
+
+// WithDestinationStyle sets how link and image urls with spaces or
+// parentheses are written, DestinationAngle by default.
+func WithDestinationStyle(style DestinationStyle) Option {
+	return func(o *options) {
+		o.destStyle = style
+	}
+}
+
+// WithMergeBlockquotes joins blockquotes separated only by whitespace into a
+// single quote, as they are often one quote split apart by an editor.
+func WithMergeBlockquotes(enable bool) Option {
+	return func(o *options) {
+		o.mergeQuotes = enable
+	}
+}
+
+// WithTrimCodeBlocks drops the line break that usually follows the opening
+// <pre> or <code> tag in the source, so code blocks do not start with an
+// empty line unless the code really has one. It is enabled by default.
+func WithTrimCodeBlocks(enable bool) Option {
+	return func(o *options) {
+		o.trimCode = enable
+	}
+}
+
+// WithDropFragmentLinks converts links to a fragment of the same page, like
+// #top, into their plain text. Other links are kept.
+func WithDropFragmentLinks(enable bool) Option {
+	return func(o *options) {
+		o.dropFragment = enable
+	}
+}
+
+// WithMathClass converts a <span> with the given class into inline math,
+// $latex$, taking the source from its data-latex attribute and falling back
+// to its text.
+func WithMathClass(class string) Option {
+	return func(o *options) {
+		o.mathClass = class
+	}
+}
+
+// WithStripZeroWidth removes zero width spaces, word joiners and byte order
+// marks from text, leaving code alone. It is enabled by default.
+func WithStripZeroWidth(enable bool) Option {
+	return func(o *options) {
+		o.zeroWidth = enable
+	}
+}
+
+// WithStyleRecognition converts inline elements styled as bold or italic
+// through their style attribute, e.g. <span style="font-weight:bold">, as
+// if they were <strong> or <em>.
+func WithStyleRecognition(enable bool) Option {
+	return func(o *options) {
+		o.styles = enable
+	}
+}
+
+// WithDoubleEncodedCode decodes character references in code blocks a
+// second time when the code looks double encoded, as some CMS exports
+// write &amp;lt; for <. Code that already holds a literal < or > is left
+// alone.
+func WithDoubleEncodedCode(enable bool) Option {
+	return func(o *options) {
+		o.doubleCode = enable
+	}
+}
+
+// WithBlankLineAfterHeadings separates a heading from the content that
+// follows it by a blank line, as many md linters require. It is enabled by
+// default; when disabled the content starts on the next line.
+func WithBlankLineAfterHeadings(enable bool) Option {
+	return func(o *options) {
+		o.headingBlank = enable
+	}
+}
+
+// WithEmptyCellPlaceholder fills empty table cells with placeholder, such
+// as "-", so the table reads better. Empty cells stay empty by default.
+func WithEmptyCellPlaceholder(placeholder string) Option {
+	return func(o *options) {
+		o.emptyCell = placeholder
+	}
+}
+
+// WithDownloadStyle sets how <a download> links are converted, DownloadLink
+// by default.
+func WithDownloadStyle(style DownloadStyle) Option {
+	return func(o *options) {
+		o.download = style
+	}
+}
+
+// WithDemoteConsecutiveHeadings demotes a heading that directly follows
+// another one of the same or a higher level to one level below it, for
+// linters that reject back to back headings of equal rank.
+func WithDemoteConsecutiveHeadings(enable bool) Option {
+	return func(o *options) {
+		o.demoteHeadings = enable
+	}
+}
+
+// WithCodeAutolinks writes a link whose only content is its own url in a
+// code span, <a href="u"><code>u</code></a>, as the autolink <u>. By
+// default it becomes [`u`](u).
+func WithCodeAutolinks(enable bool) Option {
+	return func(o *options) {
+		o.codeAutolinks = enable
+	}
+}
+
+// WithBlockquoteCite ends a <blockquote cite="url"> with an attribution
+// line, — <url>. The cite attribute is ignored by default.
+func WithBlockquoteCite(enable bool) Option {
+	return func(o *options) {
+		o.quoteCite = enable
+	}
+}
+
+// WithHighlightStyle sets how <mark> is converted, HighlightText by default.
+func WithHighlightStyle(style HighlightStyle) Option {
+	return func(o *options) {
+		o.highlight = style
+	}
+}
+
+// WithLangSpans keeps a <span lang="..."> as raw html around its converted
+// content, so the language of foreign phrases survives. By default only the
+// content is kept.
+func WithLangSpans(enable bool) Option {
+	return func(o *options) {
+		o.langSpans = enable
+	}
+}
+
+// WithHeadingStyle sets how headings are written, HeadingATX by default.
+func WithHeadingStyle(style HeadingStyle) Option {
+	return func(o *options) {
+		o.headingStyle = style
+	}
+}
+
+// WithSetextUnderlineMatchWidth makes the underline of a Setext heading as
+// wide as its text, counting east asian wide characters as two columns. By
+// default the underline is three characters long.
+func WithSetextUnderlineMatchWidth(enable bool) Option {
+	return func(o *options) {
+		o.setextWidth = enable
+	}
+}
+
+// WithSpacerParagraphs keeps paragraphs holding nothing but a <br>, which
+// editors insert as vertical spacers, as a paragraph of a single &nbsp;.
+// They are dropped by default.
+func WithSpacerParagraphs(enable bool) Option {
+	return func(o *options) {
+		o.spacers = enable
+	}
+}
+
+// WithKeepAttributes keeps inline elements whose attributes md cannot hold
+// as raw html, so that the attributes survive. For now that is <code> with a
+// class, which otherwise becomes a plain `code` span.
+func WithKeepAttributes(enable bool) Option {
+	return func(o *options) {
+		o.keepAttrs = enable
+	}
+}
+
+// WithWrapInCodeFence wraps the whole converted md in a code fence with the
+// info string lang, to show the md itself rather than have it rendered. The
+// fence is made longer than any run of backticks in the md so that code
+// blocks inside cannot close it.
+func WithWrapInCodeFence(lang string) Option {
+	return func(o *options) {
+		o.wrapFence = true
+		o.wrapLang = lang
+	}
+}
+
+// WithKeepDetails keeps <details> and its <summary> as raw html around the
+// converted content, with their attributes, so the section stays
+// collapsible and one marked open renders expanded. By default the summary
+// becomes a paragraph of its own.
+func WithKeepDetails(enable bool) Option {
+	return func(o *options) {
+		o.keepDetails = enable
+	}
+}
+
+// WithOrderedListDelimiter sets the character that follows the number of an
+// ordered list item, '.' as in 1. by default or ')' as in 1). Any other
+// character is ignored.
+func WithOrderedListDelimiter(delim rune) Option {
+	return func(o *options) {
+		if delim == '.' || delim == ')' {
+			o.olDelimiter = string(delim)
+		}
+	}
+}
+
+// WithScriptStyle sets how <sup> and <sub> are converted, ScriptText by
+// default. Footnote references are not affected.
+func WithScriptStyle(style ScriptStyle) Option {
+	return func(o *options) {
+		o.scripts = style
+	}
+}
+
+// WithDefinitionStyle sets how <dl> is converted, DefinitionPandoc by
+// default.
+func WithDefinitionStyle(style DefinitionStyle) Option {
+	return func(o *options) {
+		o.definitions = style
+	}
+}
+
+// WithEmptyLinkTarget links anchors with an empty or missing href, often
+// kept only for their title, to target, such as "#". By default they are
+// converted into their plain text.
+func WithEmptyLinkTarget(target string) Option {
+	return func(o *options) {
+		o.emptyTarget = target
+	}
+}
+
+// WithMaxAltLength shortens image alt text longer than n characters to its
+// first n characters followed by an ellipsis. A caption added by
+// WithAltCaption still holds the full text. A value of n less than or equal
+// to zero keeps alt text whole, which is the default.
+func WithMaxAltLength(n int) Option {
+	return func(o *options) {
+		o.maxAlt = n
+	}
+}
+
+// WithStrikethrough converts <del>, <s> and <strike> into the GFM
+// ~~text~~. It is enabled by default; when disabled, for CommonMark
+// renderers, they are kept as raw html.
+func WithStrikethrough(enable bool) Option {
+	return func(o *options) {
+		o.strikethrough = enable
+	}
+}
+
+// WithChoiceLists converts a <fieldset> of radio buttons or checkboxes into
+// a task list, * [x] label, that shows which options are selected, headed
+// by its legend. By default only the text of the fieldset is kept.
+func WithChoiceLists(enable bool) Option {
+	return func(o *options) {
+		o.choiceLists = enable
+	}
+}
+
+// WithSVGStyle sets how inline <svg> is converted, SVGDrop by default.
+func WithSVGStyle(style SVGStyle) Option {
+	return func(o *options) {
+		o.svg = style
+	}
+}
+
+// WithEmojiStyle sets how emoji images are converted, EmojiImage by
+// default.
+func WithEmojiStyle(style EmojiStyle) Option {
+	return func(o *options) {
+		o.emoji = style
+	}
+}
+
+// WithListIndent indents the content of list items, and so nested lists,
+// by width spaces, padding the marker to match as in *   item. By default
+// the content is indented by the width of its marker, two spaces for * and
+// three for 1. A width narrower than a marker is widened to it.
+func WithListIndent(width int) Option {
+	return func(o *options) {
+		o.listIndent = width
+	}
+}
+
+// WithListTypeMarkers numbers the items of <ol type="a">, "A", "i" and "I"
+// with letters or roman numerals, as in a. or iv. Few md renderers read
+// such markers, so by default the items are numbered 1., 2. and so on.
+func WithListTypeMarkers(enable bool) Option {
+	return func(o *options) {
+		o.typeMarkers = enable
+	}
+}
+
+// WithListSpacing sets whether list items are separated by blank lines,
+// ListAuto by default.
+func WithListSpacing(spacing ListSpacing) Option {
+	return func(o *options) {
+		o.listSpacing = spacing
+	}
+}
+
+// WithBreakStyle sets how <br> is written, BreakSpaces by default.
+func WithBreakStyle(style BreakStyle) Option {
+	return func(o *options) {
+		o.breakStyle = style
+	}
+}
+
+// WithLinkStyle sets where the destinations of links are written,
+// LinkInline by default. Links to the same destination share a number.
+func WithLinkStyle(style LinkStyle) Option {
+	return func(o *options) {
+		o.linkStyle = style
+	}
+}
+
+// WithDropDecorativeImages leaves out images with an empty alt attribute,
+// which marks them as decorative, and links holding only such an image.
+// By default they are kept as ![](src).
+func WithDropDecorativeImages(enable bool) Option {
+	return func(o *options) {
+		o.dropDecorative = enable
+	}
+}
+
+// WithStraightQuotes replaces curly quotes with straight ones, en and em
+// dashes with - and --, and ellipses with ... in text, for targets that
+// want plain ASCII punctuation. Code is left alone.
+func WithStraightQuotes(enable bool) Option {
+	return func(o *options) {
+		o.straightQuotes = enable
+	}
+}
+
+// WithCaptionStyle sets where the caption of a figure is written,
+// CaptionLine by default.
+func WithCaptionStyle(style CaptionStyle) Option {
+	return func(o *options) {
+		o.captionStyle = style
+	}
+}
+
+// WithEscaping backslash-escapes the characters in text that md would read
+// as markup, like the * in a * b or a 1. starting a line, so the text comes
+// out as written. Code is never escaped. It is enabled by default; turning
+// it off keeps text as is for input that is known to be plain.
+func WithEscaping(enable bool) Option {
+	return func(o *options) {
+		o.escape = enable
+	}
+}
+
+// WithSectionBreaks puts a thematic break between adjacent top-level
+// <section> elements to keep them apart in the md.
+func WithSectionBreaks(enable bool) Option {
+	return func(o *options) {
+		o.sectionBreaks = enable
+	}
+}
+
+// WithQuoteStyle sets the quotation marks written around a <q>,
+// QuoteStraight by default. The marks of nested quotes alternate.
+func WithQuoteStyle(style QuoteStyle) Option {
+	return func(o *options) {
+		o.quoteStyle = style
+	}
+}
+
+// WithHeadingIDs keeps the id of a heading as a trailing {#id} attribute,
+// as Pandoc and kramdown read it, so links to the heading keep working.
+// The id is written as it is. By default ids are dropped.
+func WithHeadingIDs(enable bool) Option {
+	return func(o *options) {
+		o.headingIDs = enable
+	}
+}
+
+// WithComplexTablesAsHTML keeps tables that md tables cannot show, those
+// with cells spanning several rows or columns or holding lists, code and
+// other blocks, as raw html, which GitHub renders, instead of flattening
+// them into a pipe table.
+func WithComplexTablesAsHTML(enable bool) Option {
+	return func(o *options) {
+		o.complexTables = enable
+	}
+}
+
+// WithCodeBlockFence sets the character of the fences around code blocks,
+// '`' as in ``` by default or '~' as in ~~~. Any other character is
+// ignored. Fences are made longer than any run of the character in the
+// code.
+func WithCodeBlockFence(char rune) Option {
+	return func(o *options) {
+		if char == '`' || char == '~' {
+			o.fence = string(char)
+		}
+	}
+}
+
+// WithBlankCodeSpans keeps inline code holding only whitespace as a code
+// span, like ` `, since the whitespace may matter. It is enabled by
+// default; when disabled such code is written as plain whitespace.
+func WithBlankCodeSpans(enable bool) Option {
+	return func(o *options) {
+		o.blankCode = enable
+	}
+}
+
+// WithPlainTelLinks converts tel: links, which only work on phones, into
+// their plain text. By default they are kept as links.
+func WithPlainTelLinks(enable bool) Option {
+	return func(o *options) {
+		o.plainTel = enable
+	}
+}
+
+// WithPictureSource takes the image of a <picture> from its <source>
+// elements instead of its <img>: the first one whose min-width and
+// max-width media query matches a viewport width px wide, or the first one
+// without a media query when width is 0. The <img> is used when no source
+// matches.
+func WithPictureSource(width int) Option {
+	return func(o *options) {
+		o.pictures, o.pictureWidth = true, width
+	}
+}
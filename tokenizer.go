@@ -0,0 +1,412 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2018 SmartestEE Co., Ltd..
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+/*
+ * Revision History:
+ *     Initial: 2026/10/17        Li Zebang
+ */
+
+package html2md
+
+import (
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+type tokenType int
+
+const (
+	textToken tokenType = iota
+	startTagToken
+	endTagToken
+	commentToken
+)
+
+type attribute struct {
+	key, val string
+}
+
+type token struct {
+	typ         tokenType
+	data        string
+	attrs       []attribute
+	selfClosing bool
+	offset      int
+}
+
+var (
+	// rawTextElements hold their content as text up to the matching end tag.
+	rawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+	// escapableElements decode character references in their raw text.
+	escapableElements = map[string]bool{"textarea": true, "title": true}
+)
+
+// tokenizer splits html into text, tags and comments.
+type tokenizer struct {
+	s      string
+	pos    int
+	rawTag string
+
+	// r supplies the input in chunks when reading from an io.Reader. s then
+	// holds the input from offset base on, which follows lines lines.
+	r     io.Reader
+	eof   bool
+	err   error
+	base  int
+	lines int
+}
+
+func newTokenizer(s string) *tokenizer {
+	return &tokenizer{s: s, eof: true}
+}
+
+func newReaderTokenizer(r io.Reader) *tokenizer {
+	return &tokenizer{r: r}
+}
+
+// next returns the next token, or false once the input is exhausted or
+// reading it failed.
+func (z *tokenizer) next() (token, bool) {
+	for {
+		if z.pos >= len(z.s) {
+			if z.eof || z.err != nil {
+				return token{}, false
+			}
+			z.fill()
+			continue
+		}
+
+		var (
+			pos, rawTag = z.pos, z.rawTag
+			tok         token
+		)
+		switch {
+		case z.rawTag != "":
+			tok = z.readRaw()
+		case isTagStart(z.s[z.pos:]):
+			tok = z.readTag()
+		default:
+			tok = z.readText()
+		}
+
+		// A token running up to the end of what has been read so far may
+		// go on in the next chunk, so it is read again once that is in.
+		if z.pos >= len(z.s) && !z.eof {
+			z.pos, z.rawTag = pos, rawTag
+			if z.fill(); z.err != nil {
+				return token{}, false
+			}
+			continue
+		}
+
+		tok.offset = z.base + pos
+		return tok, true
+	}
+}
+
+// fill drops the input already tokenized and reads the next chunk.
+func (z *tokenizer) fill() {
+	z.lines += strings.Count(z.s[:z.pos], "\n")
+	z.base += z.pos
+	z.s, z.pos = z.s[z.pos:], 0
+
+	size := 4096
+	if len(z.s) > size {
+		size = len(z.s)
+	}
+	buf := make([]byte, size)
+	n, err := z.r.Read(buf)
+	z.s += string(buf[:n])
+
+	switch {
+	case err == io.EOF:
+		z.eof = true
+	case err != nil:
+		z.err = err
+	}
+}
+
+// line returns the line of the input, counting from 1, at offset, which
+// must not have been dropped yet.
+func (z *tokenizer) line(offset int) int {
+	return z.lines + strings.Count(z.s[:offset-z.base], "\n") + 1
+}
+
+func (z *tokenizer) readText() token {
+	end := z.pos + 1
+	for end < len(z.s) && !isTagStart(z.s[end:]) {
+		end++
+	}
+
+	text := z.s[z.pos:end]
+	z.pos = end
+
+	return token{typ: textToken, data: unescape(text)}
+}
+
+func (z *tokenizer) readRaw() token {
+	tag := z.rawTag
+	z.rawTag = ""
+
+	end := strings.Index(strings.ToLower(z.s[z.pos:]), "</"+tag)
+	if end == -1 {
+		end = len(z.s) - z.pos
+	}
+
+	text := z.s[z.pos : z.pos+end]
+	z.pos += end
+	if escapableElements[tag] {
+		text = unescape(text)
+	}
+
+	return token{typ: textToken, data: text}
+}
+
+func (z *tokenizer) readTag() token {
+	s := z.s[z.pos:]
+
+	switch {
+	case strings.HasPrefix(s, "<!--"):
+		end := strings.Index(s[4:], "-->")
+		if end == -1 {
+			z.pos = len(z.s)
+			return token{typ: commentToken, data: s[4:]}
+		}
+		z.pos += end + 7
+		return token{typ: commentToken, data: s[4 : 4+end]}
+	case s[1] == '!' || s[1] == '?':
+		z.skipTo('>')
+		return token{typ: commentToken}
+	case s[1] == '/':
+		z.pos += 2
+		name := z.readName()
+		z.skipTo('>')
+		if name == "" {
+			return token{typ: commentToken}
+		}
+		return token{typ: endTagToken, data: name}
+	}
+
+	z.pos++
+	tok := token{typ: startTagToken, data: z.readName()}
+	z.readAttrs(&tok)
+	if rawTextElements[tok.data] && !tok.selfClosing {
+		z.rawTag = tok.data
+	}
+
+	return tok
+}
+
+func (z *tokenizer) readName() string {
+	start := z.pos
+	for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && z.s[z.pos] != '/' && z.s[z.pos] != '>' {
+		z.pos++
+	}
+
+	return strings.ToLower(z.s[start:z.pos])
+}
+
+func (z *tokenizer) readAttrs(tok *token) {
+	for {
+		z.skipSpace()
+		if z.pos >= len(z.s) {
+			return
+		}
+
+		switch z.s[z.pos] {
+		case '>':
+			z.pos++
+			return
+		case '/':
+			z.pos++
+			if z.pos < len(z.s) && z.s[z.pos] == '>' {
+				tok.selfClosing = true
+				z.pos++
+				return
+			}
+			continue
+		}
+
+		start := z.pos
+		for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && strings.IndexByte("=/>", z.s[z.pos]) == -1 {
+			z.pos++
+		}
+		key := strings.ToLower(z.s[start:z.pos])
+
+		z.skipSpace()
+		var val string
+		if z.pos < len(z.s) && z.s[z.pos] == '=' {
+			z.pos++
+			z.skipSpace()
+			val = z.readAttrValue()
+		}
+
+		if !hasAttr(tok.attrs, key) {
+			tok.attrs = append(tok.attrs, attribute{key: key, val: unescape(val)})
+		}
+	}
+}
+
+func (z *tokenizer) readAttrValue() string {
+	if z.pos >= len(z.s) {
+		return ""
+	}
+
+	if quote := z.s[z.pos]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(z.s[z.pos+1:], quote)
+		if end == -1 {
+			val := z.s[z.pos+1:]
+			z.pos = len(z.s)
+			return val
+		}
+		val := z.s[z.pos+1 : z.pos+1+end]
+		z.pos += end + 2
+		return val
+	}
+
+	start := z.pos
+	for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && z.s[z.pos] != '>' {
+		z.pos++
+	}
+
+	return z.s[start:z.pos]
+}
+
+func (z *tokenizer) skipTo(c byte) {
+	end := strings.IndexByte(z.s[z.pos:], c)
+	if end == -1 {
+		z.pos = len(z.s)
+		return
+	}
+	z.pos += end + 1
+}
+
+func (z *tokenizer) skipSpace() {
+	for z.pos < len(z.s) && isSpace(z.s[z.pos]) {
+		z.pos++
+	}
+}
+
+// isTagStart reports whether s begins with something the tokenizer reads as
+// markup rather than a literal '<'.
+func isTagStart(s string) bool {
+	if len(s) < 2 || s[0] != '<' {
+		return false
+	}
+
+	switch c := s[1]; {
+	case c == '!' || c == '?':
+		return true
+	case c == '/':
+		return len(s) > 2 && isLetter(s[2])
+	default:
+		return isLetter(c)
+	}
+}
+
+func hasAttr(attrs []attribute, key string) bool {
+	for _, a := range attrs {
+		if a.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// unescape decodes the character references in s. Some encoders write an
+// astral character as two references to its UTF-16 surrogates; such a pair
+// is combined into the character instead of two replacement characters.
+func unescape(s string) string {
+	if !strings.Contains(s, "&#") {
+		return html.UnescapeString(s)
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "&#")
+		if i == -1 {
+			break
+		}
+
+		if r1, n1 := numericRef(s[i:]); utf16.IsSurrogate(r1) {
+			if r2, n2 := numericRef(s[i+n1:]); n2 > 0 {
+				if r := utf16.DecodeRune(r1, r2); r != unicode.ReplacementChar {
+					b.WriteString(s[:i])
+					b.WriteRune(r)
+					s = s[i+n1+n2:]
+					continue
+				}
+			}
+		}
+
+		b.WriteString(s[:i+2])
+		s = s[i+2:]
+	}
+	b.WriteString(s)
+
+	return html.UnescapeString(b.String())
+}
+
+// numericRef parses the numeric character reference at the start of s and
+// returns its code point and length, or a zero length if there is none.
+func numericRef(s string) (rune, int) {
+	if !strings.HasPrefix(s, "&#") {
+		return 0, 0
+	}
+
+	i, base, digits := 2, 10, "0123456789"
+	if len(s) > 2 && (s[2] == 'x' || s[2] == 'X') {
+		i, base, digits = 3, 16, "0123456789abcdefABCDEF"
+	}
+
+	end := i
+	for end < len(s) && strings.IndexByte(digits, s[end]) != -1 {
+		end++
+	}
+	if end == i {
+		return 0, 0
+	}
+
+	n, err := strconv.ParseUint(s[i:end], base, 32)
+	if err != nil {
+		return 0, 0
+	}
+	if end < len(s) && s[end] == ';' {
+		end++
+	}
+
+	return rune(n), end
+}